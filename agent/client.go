@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// ErrWrongStore is returned by Decrypt if the agent serves a different store than the one requested.
+var ErrWrongStore = errors.New("the agent serves a different store")
+
+// Client sends requests to an agent.
+type Client struct {
+	socket  string
+	timeout time.Duration
+}
+
+// NewClient returns a Client for the agent listening on socket.
+func NewClient(socket string) *Client {
+	return &Client{socket: socket, timeout: time.Minute}
+}
+
+// FromEnvironment returns a Client for the agent named by BISCUIT_AGENT_SOCK, or nil if it is not set.
+func FromEnvironment() *Client {
+	socket := os.Getenv(SocketEnv)
+	if len(socket) == 0 {
+		return nil
+	}
+	return NewClient(socket)
+}
+
+// Socket returns the path of the agent's socket.
+func (c *Client) Socket() string {
+	return c.socket
+}
+
+// Decrypt asks the agent to decrypt the named secrets in the store at location. It returns the plaintexts
+// of the secrets that were decrypted and the errors for those that were not. The request is abandoned when
+// ctx is done.
+func (c *Client) Decrypt(ctx context.Context, location string, names, regionPriority []string) (map[string][]byte,
+	map[string]error, error) {
+	dialer := net.Dialer{Timeout: time.Second}
+	conn, err := dialer.DialContext(ctx, "unix", c.socket)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+	// Check that the agent runs as the current user before sending it anything.
+	if err := checkPeer(conn.(*net.UnixConn)); err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", c.socket, err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, nil, err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	request := Request{Store: NormalizeLocation(location), Names: names, RegionPriority: regionPriority}
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return nil, nil, err
+	}
+	var response Response
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, err
+	}
+	if response.WrongStore {
+		return nil, nil, ErrWrongStore
+	}
+	if len(response.Error) > 0 {
+		return nil, nil, errors.New(response.Error)
+	}
+	errs := make(map[string]error)
+	for name, message := range response.Errors {
+		errs[name] = errors.New(message)
+	}
+	if response.Secrets == nil {
+		response.Secrets = make(map[string][]byte)
+	}
+	return response.Secrets, errs, nil
+}
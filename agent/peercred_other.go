@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package agent
+
+import (
+	"net"
+)
+
+// checkPeer does nothing on platforms without a way to read the peer's credentials; access is controlled
+// only by the permissions of the socket.
+func checkPeer(conn *net.UnixConn) error {
+	return nil
+}
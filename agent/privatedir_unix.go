@@ -0,0 +1,22 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd
+// +build linux darwin freebsd openbsd netbsd
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkPrivateDir returns an error unless the directory described by info belongs to the current user and
+// has mode 0700.
+func checkPrivateDir(dir string, info os.FileInfo) error {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("%s: owned by uid %d", dir, stat.Uid)
+	}
+	if info.Mode().Perm() != 0700 {
+		return fmt.Errorf("%s: mode is %s, expected 0700", dir, info.Mode().Perm())
+	}
+	return nil
+}
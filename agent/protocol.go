@@ -0,0 +1,66 @@
+// Package agent implements a local process that decrypts secrets on behalf of other biscuit commands. The
+// agent keeps the data keys it has decrypted in memory for a while, so that commands started in quick
+// succession do not each have to call the key manager. Commands talk to it over a Unix socket named by
+// BISCUIT_AGENT_SOCK; only processes running as the same user may connect.
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SocketEnv names the environment variable that holds the path of the agent's socket.
+const SocketEnv = "BISCUIT_AGENT_SOCK"
+
+// Request asks the agent to decrypt secrets from a store. Each connection carries one request.
+type Request struct {
+	// Store is the location of the store, as normalized by NormalizeLocation.
+	Store string `json:"store"`
+	// Names are the secrets to decrypt.
+	Names []string `json:"names"`
+	// RegionPriority lists the AWS regions to try first.
+	RegionPriority []string `json:"region_priority,omitempty"`
+}
+
+// Response is the agent's answer to a Request.
+type Response struct {
+	// Secrets maps each name that was decrypted to its plaintext.
+	Secrets map[string][]byte `json:"secrets,omitempty"`
+	// Errors maps each name that could not be decrypted to the reason.
+	Errors map[string]string `json:"errors,omitempty"`
+	// WrongStore is set if the agent does not serve the requested store.
+	WrongStore bool `json:"wrong_store,omitempty"`
+	// Error is set if the request failed as a whole.
+	Error string `json:"error,omitempty"`
+}
+
+// NormalizeLocation returns a form of a store location that is the same in every working directory, so that
+// the agent can tell whether a request is for the store it serves.
+func NormalizeLocation(location string) string {
+	location = strings.TrimPrefix(location, "file://")
+	if strings.HasPrefix(location, "dir://") {
+		return "dir://" + absPath(strings.TrimPrefix(location, "dir://"))
+	}
+	if strings.Contains(location, "://") {
+		return location
+	}
+	return absPath(location)
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// DefaultSocketPath returns the socket path used when none is specified: a file in $XDG_RUNTIME_DIR, or
+// else in a per-user directory under the temporary directory.
+func DefaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); len(dir) > 0 {
+		return filepath.Join(dir, "biscuit-agent.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("biscuit-agent-%d", os.Getuid()), "agent.sock")
+}
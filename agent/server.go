@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Handler answers a request. It may be called concurrently.
+type Handler func(request Request) Response
+
+// Server accepts connections on a Unix socket and answers the requests of processes running as the same
+// user.
+type Server struct {
+	path     string
+	listener *net.UnixListener
+	handler  Handler
+
+	mu     sync.Mutex
+	closed bool
+	conns  map[*net.UnixConn]struct{}
+	active sync.WaitGroup
+}
+
+// Listen creates a socket at path that only the current user can connect to. If path already exists but no
+// agent is listening on it, it is replaced. The directory of path is created if necessary, and must be a
+// directory (not a symbolic link) that belongs to the current user and that no one else can access.
+func Listen(path string, handler Handler) (*Server, error) {
+	if err := prepareSocketDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("an agent is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &Server{path: path, listener: listener, handler: handler, conns: make(map[*net.UnixConn]struct{})}, nil
+}
+
+// prepareSocketDir creates dir if it does not exist and checks that another user cannot have created or
+// replaced it, which would let them substitute their own socket.
+func prepareSocketDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s: not a directory", dir)
+	}
+	return checkPrivateDir(dir, info)
+}
+
+// Path returns the path of the socket.
+func (s *Server) Path() string {
+	return s.path
+}
+
+// Serve accepts connections until Close is called. It then waits for the connections being served to finish,
+// so that the handler is no longer called once it returns.
+func (s *Server) Serve() error {
+	defer s.active.Wait()
+	for {
+		conn, err := s.listener.AcceptUnix()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed || conn.SetDeadline(time.Now().Add(5*time.Minute)) != nil {
+			s.mu.Unlock()
+			conn.Close()
+			continue
+		}
+		s.conns[conn] = struct{}{}
+		s.active.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops accepting connections and interrupts those waiting for a request or for their response to be
+// read. Closing the listener also removes the socket.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.SetDeadline(time.Now())
+	}
+	s.mu.Unlock()
+	return s.listener.Close()
+}
+
+func (s *Server) serveConn(conn *net.UnixConn) {
+	defer s.active.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+	defer conn.Close()
+	if err := checkPeer(conn); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: rejected connection: %s\n", err)
+		return
+	}
+	var request Request
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		json.NewEncoder(conn).Encode(Response{Error: fmt.Sprintf("malformed request: %s", err)})
+		return
+	}
+	json.NewEncoder(conn).Encode(s.handler(request))
+}
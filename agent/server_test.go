@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "agent", "agent.sock")
+	location := NormalizeLocation("secrets.yml")
+	server, err := Listen(socket, func(request Request) Response {
+		if request.Store != location {
+			return Response{WrongStore: true}
+		}
+		response := Response{Secrets: make(map[string][]byte), Errors: make(map[string]string)}
+		for _, name := range request.Names {
+			if name == "missing" {
+				response.Errors[name] = "name not found"
+			} else {
+				response.Secrets[name] = []byte(name + ":" + request.RegionPriority[0])
+			}
+		}
+		return response
+	})
+	require.NoError(t, err)
+	done := make(chan error)
+	go func() { done <- server.Serve() }()
+
+	info, err := os.Stat(socket)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	_, err = Listen(socket, nil)
+	assert.Error(t, err, "a second agent should not replace a running one")
+
+	client := NewClient(socket)
+	plaintexts, errs, err := client.Decrypt(context.Background(), "secrets.yml", []string{"password", "missing"},
+		[]string{"us-west-2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"password": []byte("password:us-west-2")}, plaintexts)
+	assert.EqualError(t, errs["missing"], "name not found")
+
+	_, _, err = client.Decrypt(context.Background(), "other.yml", []string{"password"}, nil)
+	assert.Equal(t, ErrWrongStore, err)
+
+	require.NoError(t, server.Close())
+	assert.NoError(t, <-done)
+	_, _, err = client.Decrypt(context.Background(), "secrets.yml", []string{"password"}, nil)
+	assert.Error(t, err)
+}
+
+func TestClient_Canceled(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "agent", "agent.sock")
+	release := make(chan struct{})
+	server, err := Listen(socket, func(request Request) Response {
+		<-release
+		return Response{}
+	})
+	require.NoError(t, err)
+	go server.Serve()
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err = NewClient(socket).Decrypt(ctx, "secrets.yml", []string{"password"}, nil)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestServer_CloseWaitsForRequests(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "agent", "agent.sock")
+	started, release := make(chan struct{}), make(chan struct{})
+	server, err := Listen(socket, func(request Request) Response {
+		close(started)
+		<-release
+		return Response{}
+	})
+	require.NoError(t, err)
+	done := make(chan error)
+	go func() { done <- server.Serve() }()
+	go NewClient(socket).Decrypt(context.Background(), "secrets.yml", []string{"password"}, nil)
+	<-started
+
+	require.NoError(t, server.Close())
+	_, err = os.Stat(socket)
+	assert.True(t, os.IsNotExist(err), "Close should remove the socket")
+	select {
+	case <-done:
+		t.Error("Serve should wait for the request being handled")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	assert.NoError(t, <-done)
+}
+
+func TestNormalizeLocation(t *testing.T) {
+	abs, err := filepath.Abs("secrets.yml")
+	require.NoError(t, err)
+	assert.Equal(t, abs, NormalizeLocation("secrets.yml"))
+	assert.Equal(t, abs, NormalizeLocation("file://secrets.yml"))
+	assert.Equal(t, "s3://bucket/secrets.yml", NormalizeLocation("s3://bucket/secrets.yml"))
+	dir, err := filepath.Abs("secrets")
+	require.NoError(t, err)
+	assert.Equal(t, "dir://"+dir, NormalizeLocation("dir://secrets/"))
+	assert.Equal(t, "dir://"+dir, NormalizeLocation("dir://"+dir))
+}
+
+func TestListen_socketDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires Unix permissions")
+	}
+	dir := t.TempDir()
+	shared := filepath.Join(dir, "shared")
+	require.NoError(t, os.Mkdir(shared, 0755))
+	require.NoError(t, os.Chmod(shared, 0755))
+	_, err := Listen(filepath.Join(shared, "agent.sock"), nil)
+	assert.Error(t, err, "the directory should only be accessible by its owner")
+
+	private := filepath.Join(dir, "private")
+	require.NoError(t, os.Mkdir(private, 0700))
+	link := filepath.Join(dir, "link")
+	require.NoError(t, os.Symlink(private, link))
+	_, err = Listen(filepath.Join(link, "agent.sock"), nil)
+	assert.Error(t, err, "the directory should not be a symbolic link")
+
+	server, err := Listen(filepath.Join(dir, "created", "agent.sock"), nil)
+	require.NoError(t, err)
+	require.NoError(t, server.Close())
+	info, err := os.Stat(filepath.Join(dir, "created"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+}
@@ -4,23 +4,44 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"errors"
 )
 
 const (
 	aesGcmLabel = "aesgcm256"
+	// aesGcmV2Label is AES-GCM-256 with the additional data authenticated along with the ciphertext.
+	aesGcmV2Label = "aesgcm256-v2"
 )
 
 func init() {
 	registry[aesGcmLabel] = newAesGcm256
+	registry[aesGcmV2Label] = newAesGcm256V2
 }
 
-type aesGcm256 struct{}
+var errCiphertextTooShort = errors.New("aesgcm256: ciphertext too short")
+
+type aesGcm256 struct {
+	label string
+	// bindAdditionalData is false for the original aesgcm256 label, which predates additional data support.
+	bindAdditionalData bool
+}
 
 func newAesGcm256() Algorithm {
-	return &aesGcm256{}
+	return &aesGcm256{label: aesGcmLabel}
+}
+
+func newAesGcm256V2() Algorithm {
+	return &aesGcm256{label: aesGcmV2Label, bindAdditionalData: true}
+}
+
+func (c *aesGcm256) additionalData(additionalData []byte) []byte {
+	if c.bindAdditionalData {
+		return additionalData
+	}
+	return nil
 }
 
-func (c *aesGcm256) Encrypt(key []byte, data []byte) ([]byte, error) {
+func (c *aesGcm256) Encrypt(key []byte, data []byte, additionalData []byte) ([]byte, error) {
 	aes, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -36,12 +57,12 @@ func (c *aesGcm256) Encrypt(key []byte, data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	ciphertext := block.Seal(nil, nonce, data, nil)
+	ciphertext := block.Seal(nil, nonce, data, c.additionalData(additionalData))
 	ciphertext = append(ciphertext, nonce...)
 	return ciphertext, nil
 }
 
-func (c *aesGcm256) Decrypt(key []byte, ciphertext []byte) ([]byte, error) {
+func (c *aesGcm256) Decrypt(key []byte, ciphertext []byte, additionalData []byte) ([]byte, error) {
 	aes, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -50,15 +71,23 @@ func (c *aesGcm256) Decrypt(key []byte, ciphertext []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(ciphertext) < block.NonceSize() {
+		return nil, errCiphertextTooShort
+	}
 	nonce := ciphertext[len(ciphertext)-block.NonceSize():]
-	plaintext, err := block.Open(nil, nonce, ciphertext[:len(ciphertext)-block.NonceSize()], nil)
+	plaintext, err := block.Open(nil, nonce, ciphertext[:len(ciphertext)-block.NonceSize()],
+		c.additionalData(additionalData))
 	return plaintext, err
 }
 
 func (c *aesGcm256) Label() string {
-	return aesGcmLabel
+	return c.label
 }
 
 func (c *aesGcm256) NeedsKey() bool {
 	return true
 }
+
+func (c *aesGcm256) BindsAdditionalData() bool {
+	return c.bindAdditionalData
+}
@@ -10,12 +10,15 @@ var (
 	errUnsupportedAlgorithm = errors.New("algorithms: unsupported algorithm")
 )
 
-// Algorithm implementations encrypt and decrypt data.
+// Algorithm implementations encrypt and decrypt data. additionalData is authenticated but not encrypted by
+// algorithms that support it, and ignored by those that do not.
 type Algorithm interface {
-	Encrypt(key []byte, data []byte) ([]byte, error)
-	Decrypt(key []byte, ciphertext []byte) ([]byte, error)
+	Encrypt(key []byte, data []byte, additionalData []byte) ([]byte, error)
+	Decrypt(key []byte, ciphertext []byte, additionalData []byte) ([]byte, error)
 	Label() string
 	NeedsKey() bool
+	// BindsAdditionalData reports whether the additional data is authenticated.
+	BindsAdditionalData() bool
 }
 
 // New returns an Algorithm corresponding to the requested cipher.
@@ -26,7 +29,9 @@ func New(algorithm string) (Algorithm, error) {
 	return nil, errUnsupportedAlgorithm
 }
 
-// GetDefaultAlgorithm returns the default algorithm.
+// GetDefaultAlgorithm returns the default algorithm. It does not authenticate the additional data, so that
+// files written by default remain readable by older releases; aesgcm256-v2 and xchacha20poly1305 must be
+// chosen explicitly.
 func GetDefaultAlgorithm() string {
 	return secretBoxLabel
 }
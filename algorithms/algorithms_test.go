@@ -13,6 +13,7 @@ func TestAlgorithms(t *testing.T) {
 	var testInputs = []string{"", " ", "a", "ab", "12345678", "123456789",
 		strings.Repeat("beef", 128)}
 
+	additionalData := []byte("name")
 	var key, wrongKey [32]byte
 	_, err := rand.Read(key[:])
 	assert.NoError(t, err)
@@ -26,16 +27,16 @@ func TestAlgorithms(t *testing.T) {
 		algo, err := New(label)
 		assert.NoError(t, err)
 		for _, expected := range testInputs {
-			ciphertext, err := algo.Encrypt(key[:], []byte(expected))
+			ciphertext, err := algo.Encrypt(key[:], []byte(expected), additionalData)
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
 			// attempt to decrypt with wrong key
-			if _, err = algo.Decrypt(wrongKey[:], ciphertext); err == nil {
+			if _, err = algo.Decrypt(wrongKey[:], ciphertext, additionalData); err == nil {
 				t.Errorf("expected error but didn't get one")
 			}
 			// decrypt with correct key
-			plaintext, err := algo.Decrypt(key[:], ciphertext)
+			plaintext, err := algo.Decrypt(key[:], ciphertext, additionalData)
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
@@ -46,8 +47,34 @@ func TestAlgorithms(t *testing.T) {
 			// mutate a few bytes and verify that it fails to decrypt
 			_, err = rand.Read(ciphertext[0:4])
 			assert.NoError(t, err)
-			_, err = algo.Decrypt(key[:], ciphertext)
+			_, err = algo.Decrypt(key[:], ciphertext, additionalData)
 			assert.Error(t, err)
 		}
 	}
 }
+
+func TestAdditionalDataIsAuthenticated(t *testing.T) {
+	var key [32]byte
+	_, err := rand.Read(key[:])
+	assert.NoError(t, err)
+
+	var binding []string
+	for _, label := range GetAlgorithms() {
+		algo, err := New(label)
+		assert.NoError(t, err)
+		if !algo.BindsAdditionalData() {
+			continue
+		}
+		binding = append(binding, label)
+		ciphertext, err := algo.Encrypt(key[:], []byte("launch codes"), []byte("name"))
+		assert.NoError(t, err)
+		_, err = algo.Decrypt(key[:], ciphertext, []byte("other name"))
+		assert.Error(t, err, label)
+		_, err = algo.Decrypt(key[:], ciphertext, nil)
+		assert.Error(t, err, label)
+		plaintext, err := algo.Decrypt(key[:], ciphertext, []byte("name"))
+		assert.NoError(t, err, label)
+		assert.Equal(t, []byte("launch codes"), plaintext)
+	}
+	assert.Equal(t, []string{aesGcmV2Label, xChaCha20Poly1305Label}, binding)
+}
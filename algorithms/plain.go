@@ -14,11 +14,11 @@ func newPlain() Algorithm {
 	return &plain{}
 }
 
-func (s *plain) Encrypt(_ []byte, data []byte) ([]byte, error) {
+func (s *plain) Encrypt(_ []byte, data []byte, _ []byte) ([]byte, error) {
 	return data, nil
 }
 
-func (s *plain) Decrypt(_ []byte, ciphertext []byte) ([]byte, error) {
+func (s *plain) Decrypt(_ []byte, ciphertext []byte, _ []byte) ([]byte, error) {
 	return ciphertext, nil
 }
 
@@ -29,3 +29,7 @@ func (s *plain) Label() string {
 func (s *plain) NeedsKey() bool {
 	return false
 }
+
+func (s *plain) BindsAdditionalData() bool {
+	return false
+}
@@ -26,7 +26,7 @@ func newSecretBox() Algorithm {
 	return &secretBox{}
 }
 
-func (s *secretBox) Encrypt(key []byte, data []byte) ([]byte, error) {
+func (s *secretBox) Encrypt(key []byte, data []byte, _ []byte) ([]byte, error) {
 	var nonce [24]byte
 	if _, err := rand.Read(nonce[:]); err != nil {
 		return nil, err
@@ -36,7 +36,10 @@ func (s *secretBox) Encrypt(key []byte, data []byte) ([]byte, error) {
 	return secretbox.Seal(nonce[:], data, &nonce, &keyArr), nil
 }
 
-func (s *secretBox) Decrypt(key []byte, ciphertext []byte) ([]byte, error) {
+func (s *secretBox) Decrypt(key []byte, ciphertext []byte, _ []byte) ([]byte, error) {
+	if len(ciphertext) < 24 {
+		return nil, errUnableToDecrypt
+	}
 	var nonce [24]byte
 	copy(nonce[:], ciphertext[:24])
 	var keyArr [32]byte
@@ -56,3 +59,7 @@ func (s *secretBox) Label() string {
 func (s *secretBox) NeedsKey() bool {
 	return true
 }
+
+func (s *secretBox) BindsAdditionalData() bool {
+	return false
+}
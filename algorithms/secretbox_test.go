@@ -19,7 +19,7 @@ func TestSecretboxSymmetry(t *testing.T) {
 		var message [4096]byte
 		_, err := rand.Read(message[:])
 		assert.NoError(t, err)
-		ciphertext, err := box.Encrypt(key[:], message[:])
+		ciphertext, err := box.Encrypt(key[:], message[:], nil)
 
 		// Test that nonces seem to be unique
 		nonce := base64.StdEncoding.EncodeToString(ciphertext[:24])
@@ -28,7 +28,7 @@ func TestSecretboxSymmetry(t *testing.T) {
 		nonces[nonce] = struct{}{}
 
 		assert.NoError(t, err)
-		plaintext, err := box.Decrypt(key[:], ciphertext)
+		plaintext, err := box.Decrypt(key[:], ciphertext, nil)
 		assert.NoError(t, err)
 		assert.Equal(t, message[:], plaintext)
 	}
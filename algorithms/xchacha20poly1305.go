@@ -0,0 +1,66 @@
+package algorithms
+
+import (
+	"crypto/rand"
+	"errors"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	xChaCha20Poly1305Label = "xchacha20poly1305"
+)
+
+func init() {
+	registry[xChaCha20Poly1305Label] = newXChaCha20Poly1305
+}
+
+var errXChaCha20Poly1305TooShort = errors.New("xchacha20poly1305: ciphertext too short")
+
+// xChaCha20Poly1305 is an AEAD with 24-byte nonces, which are large enough to be chosen at random without
+// risk of collision. The nonce is prepended to the ciphertext.
+type xChaCha20Poly1305 struct{}
+
+func newXChaCha20Poly1305() Algorithm {
+	return &xChaCha20Poly1305{}
+}
+
+func (x *xChaCha20Poly1305) Encrypt(key []byte, data []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return x.seal(key, nonce, data, additionalData)
+}
+
+func (x *xChaCha20Poly1305) seal(key, nonce, data, additionalData []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, additionalData), nil
+}
+
+func (x *xChaCha20Poly1305) Decrypt(key []byte, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, errXChaCha20Poly1305TooShort
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+	return aead.Open(nil, nonce, ciphertext[aead.NonceSize():], additionalData)
+}
+
+func (x *xChaCha20Poly1305) Label() string {
+	return xChaCha20Poly1305Label
+}
+
+func (x *xChaCha20Poly1305) NeedsKey() bool {
+	return true
+}
+
+func (x *xChaCha20Poly1305) BindsAdditionalData() bool {
+	return true
+}
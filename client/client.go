@@ -0,0 +1,206 @@
+// Package client reads and writes biscuit files from Go programs, so that applications can load their
+// secrets at startup without running the biscuit binary. For example:
+//
+//	secrets, err := client.Open("secrets.yml", &client.Options{RegionPriority: []string{"us-west-2"}})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer secrets.Close()
+//	password, err := secrets.Get(ctx, "db-password")
+//
+// Locations are the same as those accepted by the --filename flag of the biscuit commands.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/primait/biscuit/internal/envelope"
+	"github.com/primait/biscuit/internal/keycache"
+	"github.com/primait/biscuit/keymanager"
+	"github.com/primait/biscuit/store"
+)
+
+// Options configure a Client. The zero value is valid.
+type Options struct {
+	// RegionPriority lists the AWS regions whose KMS keys are tried first when decrypting.
+	RegionPriority []string
+	// KeyManagers replace the registered key managers with the same labels, ex: to use a KMS key manager
+	// with custom credentials, or a fake one in tests.
+	KeyManagers []keymanager.KeyManager
+	// CacheTTL, if positive, keeps decrypted data keys in memory for this long, so that reading a secret
+	// again does not call the key manager.
+	CacheTTL time.Duration
+	// Parallelism is the number of secrets GetAll decrypts concurrently. It defaults to 8.
+	Parallelism int
+	// Author is recorded as the updated_by metadata of the secrets written by Put.
+	Author string
+}
+
+// Client reads and writes the secrets in one store. It is safe for concurrent use.
+type Client struct {
+	database store.Store
+	options  Options
+	dataKeys envelope.DataKeyDecrypter
+	managers envelope.KeyManagers
+	cache    *keycache.Cache
+}
+
+// Open returns a Client for the store at location. options may be nil.
+func Open(location string, options *Options) (*Client, error) {
+	database, err := store.Open(location)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{database: database}
+	if options != nil {
+		c.options = *options
+	}
+	if c.options.Parallelism < 1 {
+		c.options.Parallelism = 8
+	}
+	injected := make(map[string]keymanager.KeyManager)
+	for _, keyManager := range c.options.KeyManagers {
+		injected[keyManager.Label()] = keyManager
+	}
+	c.managers = func(label string) (keymanager.KeyManager, error) {
+		if keyManager, present := injected[label]; present {
+			return keyManager, nil
+		}
+		return keymanager.New(label)
+	}
+	c.dataKeys = envelope.DataKeys(c.managers)
+	if c.options.CacheTTL > 0 {
+		c.cache = keycache.New(c.options.CacheTTL, 0)
+		c.dataKeys = envelope.CachedDataKeys(c.cache, c.dataKeys)
+	}
+	return c, nil
+}
+
+// Close discards any cached data keys.
+func (c *Client) Close() {
+	if c.cache != nil {
+		c.cache.Close()
+	}
+}
+
+// Names returns the sorted names of the secrets in the store.
+func (c *Client) Names() ([]string, error) {
+	entries, err := c.database.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return entries.SecretNames(), nil
+}
+
+// Get returns the plaintext of a secret. It returns store.ErrNameNotFound if there is no such secret.
+func (c *Client) Get(ctx context.Context, name string) ([]byte, error) {
+	if name == store.KeyTemplateName {
+		return nil, store.ErrNameNotFound
+	}
+	values, err := c.database.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.decrypt(ctx, name, values)
+}
+
+// GetAll returns the plaintexts of every secret in the store.
+func (c *Client) GetAll(ctx context.Context) (map[string][]byte, error) {
+	entries, err := c.database.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	names := entries.SecretNames()
+
+	var mu sync.Mutex
+	plaintexts := make(map[string][]byte)
+	errs := make(map[string]error)
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < c.options.Parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				plaintext, err := c.decrypt(ctx, name, entries[name])
+				mu.Lock()
+				if err != nil {
+					errs[name] = err
+				} else {
+					plaintexts[name] = plaintext
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, name := range names {
+		work <- name
+	}
+	close(work)
+	wg.Wait()
+
+	for _, name := range names {
+		if err, present := errs[name]; present {
+			return nil, err
+		}
+	}
+	return plaintexts, nil
+}
+
+// Put encrypts plaintext under the keys in the store's key template and writes it, replacing any existing
+// secret with the same name. The description, tags and content type of an existing secret are kept. It
+// returns an error wrapping store.ErrConflict if the secret is changed by another writer meanwhile.
+func (c *Client) Put(ctx context.Context, name string, plaintext []byte) error {
+	if name == store.KeyTemplateName {
+		return fmt.Errorf("the %s entry cannot be written by Put", store.KeyTemplateName)
+	}
+	keys, err := c.database.GetKeyIds()
+	if err != nil {
+		return err
+	}
+	previous, version, err := c.database.GetVersion(name)
+	if err != nil && err != store.ErrNameNotFound {
+		return err
+	}
+	metadata := &store.Metadata{}
+	if previous.Metadata() != nil {
+		*metadata = *previous.Metadata()
+	}
+	metadata.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	metadata.UpdatedBy = c.options.Author
+
+	values, err := envelope.EncryptAll(ctx, c.managers, keys, name, plaintext)
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	for i := range values {
+		values[i].Metadata = metadata
+	}
+	return c.database.PutIfVersion(version, name, values)
+}
+
+// decrypt returns the plaintext of the first of values that can be decrypted, trying them in order of
+// region priority.
+func (c *Client) decrypt(ctx context.Context, name string, values store.ValueList) ([]byte, error) {
+	values = append(store.ValueList{}, values...)
+	store.SortByKmsRegion(c.options.RegionPriority)(values)
+	if c.cache != nil {
+		c.cache.Expire()
+	}
+	err := errors.New("no values")
+	for _, value := range values {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		var plaintext []byte
+		plaintext, err = envelope.Decrypt(ctx, value, name, c.dataKeys)
+		if err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %s", name, err)
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/primait/biscuit/keymanager"
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	westKey = "arn:aws:kms:us-west-2:123456789012:key/west"
+	eastKey = "arn:aws:kms:us-east-1:123456789012:key/east"
+)
+
+// fakeKms is a key manager that uses a constant key and fails to decrypt under the keys in broken.
+type fakeKms struct {
+	broken   map[string]bool
+	decrypts []string
+}
+
+func (f *fakeKms) GenerateEnvelopeKey(ctx context.Context, keyID, secretID string) (keymanager.EnvelopeKey, error) {
+	return keymanager.EnvelopeKey{
+		ResolvedID: keyID,
+		Plaintext:  bytes.Repeat([]byte{'k'}, 32),
+		Ciphertext: []byte(secretID),
+	}, nil
+}
+
+func (f *fakeKms) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte,
+	error) {
+	f.decrypts = append(f.decrypts, keyID)
+	if f.broken[keyID] {
+		return nil, errors.New("access denied")
+	}
+	if string(keyCiphertext) != secretID {
+		return nil, errors.New("wrong secret")
+	}
+	return bytes.Repeat([]byte{'k'}, 32), nil
+}
+
+func (f *fakeKms) Label() string {
+	return keymanager.KmsLabel
+}
+
+func newTestStore(t *testing.T, keys ...store.Key) string {
+	dir, err := ioutil.TempDir("", "TestClient")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	filename := filepath.Join(dir, "secrets.yml")
+	var template store.ValueList
+	for _, key := range keys {
+		template = append(template, store.Value{Key: key})
+	}
+	require.NoError(t, store.NewFileStore(filename).Put(store.KeyTemplateName, template))
+	return filename
+}
+
+func TestClient_PutGet(t *testing.T) {
+	filename := newTestStore(t, store.Key{Algorithm: "none"})
+	c, err := Open(filename, &Options{Author: "oreilly"})
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx := context.Background()
+	require.NoError(t, c.Put(ctx, "launch-codes", []byte("0000")))
+	require.NoError(t, c.Put(ctx, "password", []byte("hunter2")))
+
+	plaintext, err := c.Get(ctx, "launch-codes")
+	require.NoError(t, err)
+	assert.Equal(t, "0000", string(plaintext))
+
+	all, err := c.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"launch-codes": []byte("0000"), "password": []byte("hunter2")}, all)
+
+	names, err := c.Names()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"launch-codes", "password"}, names)
+
+	values, err := store.NewFileStore(filename).Get("password")
+	require.NoError(t, err)
+	require.NotNil(t, values.Metadata())
+	assert.Equal(t, "oreilly", values.Metadata().UpdatedBy)
+
+	_, err = c.Get(ctx, "missing")
+	assert.Equal(t, store.ErrNameNotFound, err)
+	_, err = c.Get(ctx, store.KeyTemplateName)
+	assert.Equal(t, store.ErrNameNotFound, err)
+}
+
+func TestClient_RegionPriority(t *testing.T) {
+	filename := newTestStore(t,
+		store.Key{KeyID: eastKey, KeyManager: keymanager.KmsLabel, Algorithm: "aesgcm256-v2"},
+		store.Key{KeyID: westKey, KeyManager: keymanager.KmsLabel, Algorithm: "aesgcm256-v2"})
+	kms := &fakeKms{broken: map[string]bool{eastKey: true}}
+	c, err := Open(filename, &Options{KeyManagers: []keymanager.KeyManager{kms}})
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, c.Put(ctx, "password", []byte("hunter2")))
+
+	plaintext, err := c.Get(ctx, "password")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", string(plaintext))
+	assert.Equal(t, []string{eastKey, westKey}, kms.decrypts)
+
+	kms.decrypts = nil
+	c, err = Open(filename, &Options{KeyManagers: []keymanager.KeyManager{kms},
+		RegionPriority: []string{"us-west-2"}})
+	require.NoError(t, err)
+	_, err = c.Get(ctx, "password")
+	require.NoError(t, err)
+	assert.Equal(t, []string{westKey}, kms.decrypts)
+
+	kms.broken[westKey] = true
+	_, err = c.Get(ctx, "password")
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "password: "))
+}
+
+func TestClient_Cache(t *testing.T) {
+	filename := newTestStore(t, store.Key{KeyID: westKey, KeyManager: keymanager.KmsLabel,
+		Algorithm: "aesgcm256-v2"})
+	kms := &fakeKms{}
+	c, err := Open(filename, &Options{KeyManagers: []keymanager.KeyManager{kms}, CacheTTL: time.Minute})
+	require.NoError(t, err)
+	defer c.Close()
+	ctx := context.Background()
+	require.NoError(t, c.Put(ctx, "password", []byte("hunter2")))
+
+	for i := 0; i < 3; i++ {
+		plaintext, err := c.Get(ctx, "password")
+		require.NoError(t, err)
+		assert.Equal(t, "hunter2", string(plaintext))
+	}
+	assert.Len(t, kms.decrypts, 1)
+}
+
+func TestClient_Canceled(t *testing.T) {
+	filename := newTestStore(t, store.Key{Algorithm: "none"})
+	c, err := Open(filename, nil)
+	require.NoError(t, err)
+	require.NoError(t, c.Put(context.Background(), "password", []byte("hunter2")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.Get(ctx, "password")
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/primait/biscuit/agent"
+	"github.com/primait/biscuit/internal/envelope"
+	"github.com/primait/biscuit/internal/keycache"
+	"github.com/primait/biscuit/shared"
+	"github.com/primait/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type agentCommand struct {
+	filename, socket *string
+	ttl              *time.Duration
+	parallelism      *int
+}
+
+// NewAgent configures the command to run an agent that decrypts secrets for other commands.
+func NewAgent(c *kingpin.CmdClause) shared.Command {
+	return &agentCommand{
+		socket: c.Flag("socket", "Path of the Unix socket to listen on. Its directory must have mode 0700.").
+			Default(agent.DefaultSocketPath()).
+			String(),
+		ttl: c.Flag("ttl", "How long to keep each decrypted data key in memory.").
+			Default("15m").
+			Duration(),
+		parallelism: shared.ParallelismFlag(c),
+		filename:    shared.FilenameFlag(c),
+	}
+}
+
+// Run the command.
+func (r *agentCommand) Run(ctx context.Context) error {
+	backing := &agentStore{location: agent.NormalizeLocation(*r.filename)}
+	if _, err := backing.load(); err != nil {
+		return err
+	}
+	cache := keycache.New(*r.ttl, 0)
+	// Serve returns once the connections being served have finished, so no request can cache a key after this.
+	defer cache.Close()
+	server, err := agent.Listen(*r.socket, func(request agent.Request) agent.Response {
+		return serveAgentRequest(ctx, backing, cache, request, *r.parallelism)
+	})
+	if err != nil {
+		return err
+	}
+
+	interval := time.Minute
+	if *r.ttl < interval {
+		interval = *r.ttl
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				cache.Expire()
+				if dataKeys := keyManagerOptions(ctx).Cache; dataKeys != nil {
+					dataKeys.Expire()
+				}
+			case <-ctx.Done():
+				server.Close()
+				return
+			}
+		}
+	}()
+
+	fmt.Printf("%s=%s; export %s;\n", agent.SocketEnv, server.Path(), agent.SocketEnv)
+	fmt.Fprintf(os.Stderr, "Serving %s on %s. Press Ctrl-C to stop.\n", backing.location, server.Path())
+	return server.Serve()
+}
+
+// agentStore is the store served by the agent. Local files are read again when they change; other
+// locations are read on every request.
+type agentStore struct {
+	location string
+
+	mu      sync.Mutex
+	entries store.EntryMap
+	loaded  bool
+	modTime time.Time
+	size    int64
+}
+
+func (s *agentStore) load() (store.EntryMap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, statErr := os.Stat(s.location)
+	if s.loaded && statErr == nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.entries, nil
+	}
+	database, err := store.Open(s.location)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := database.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	s.entries = entries
+	s.loaded = true
+	if statErr == nil {
+		s.modTime = info.ModTime()
+		s.size = info.Size()
+	}
+	return entries, nil
+}
+
+// serveAgentRequest decrypts the secrets named in a request, caching the data keys.
+func serveAgentRequest(ctx context.Context, backing *agentStore, cache *keycache.Cache, request agent.Request,
+	parallelism int) agent.Response {
+	if request.Store != backing.location {
+		return agent.Response{WrongStore: true}
+	}
+	entries, err := backing.load()
+	if err != nil {
+		return agent.Response{Error: err.Error()}
+	}
+
+	getKey := envelope.CachedDataKeys(cache, envelope.DataKeys(keyManagers(ctx)))
+	var mu sync.Mutex
+	response := agent.Response{Secrets: make(map[string][]byte), Errors: make(map[string]string)}
+	sortByRegion := store.SortByKmsRegion(request.RegionPriority)
+	forEachParallel(request.Names, parallelism, func(name string) {
+		values, present := entries[name]
+		err := store.ErrNameNotFound
+		var plaintext []byte
+		if present && name != store.KeyTemplateName {
+			values = append(store.ValueList{}, values...)
+			sortByRegion(values)
+			for _, value := range values {
+				if plaintext, err = envelope.Decrypt(ctx, value, name, getKey); err == nil {
+					break
+				}
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			response.Errors[name] = err.Error()
+			return
+		}
+		response.Secrets[name] = plaintext
+	})
+	return response
+}
+
+// agentDecrypt decrypts the named secrets through the agent if BISCUIT_AGENT_SOCK is set. ok is false if
+// the caller should decrypt the secrets itself: when no agent is configured, when the agent serves another
+// store, or when it cannot be reached.
+func agentDecrypt(ctx context.Context, location string, names, regionPriority []string) (map[string][]byte,
+	map[string]error, bool) {
+	client := agent.FromEnvironment()
+	if client == nil {
+		return nil, nil, false
+	}
+	plaintexts, errs, err := client.Decrypt(ctx, location, names, regionPriority)
+	if err == agent.ErrWrongStore {
+		return nil, nil, false
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: unable to use the agent at %s: %s\n", client.Socket(), err)
+		return nil, nil, false
+	}
+	return plaintexts, errs, true
+}
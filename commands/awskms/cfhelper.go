@@ -1,6 +1,7 @@
 package awskms
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -27,7 +28,7 @@ func (s *cloudformationStack) parameterList() (output []*cloudformation.Paramete
 	return
 }
 
-func (s *cloudformationStack) createAndWait() (map[string]string, error) {
+func (s *cloudformationStack) createAndWait(ctx context.Context) (map[string]string, error) {
 	cfclient := cloudformation.New(shared.GetNewSessionWithRegion(s.region))
 	createStackInput := &cloudformation.CreateStackInput{
 		StackName:    &s.stackName,
@@ -37,16 +38,16 @@ func (s *cloudformationStack) createAndWait() (map[string]string, error) {
 		TemplateBody: s.templateBody,
 		TemplateURL:  s.templateURL,
 	}
-	createStackOutput, err := cfclient.CreateStack(createStackInput)
+	createStackOutput, err := cfclient.CreateStackWithContext(ctx, createStackInput)
 	if err != nil {
 		return nil, err
 	}
 	fmt.Printf("%s: Waiting for CloudFormation stack %s.\n", s.region, *createStackOutput.StackId)
 	describeStackInput := &cloudformation.DescribeStacksInput{StackName: createStackOutput.StackId}
-	if err := cfclient.WaitUntilStackCreateComplete(describeStackInput); err != nil {
+	if err := cfclient.WaitUntilStackCreateCompleteWithContext(ctx, describeStackInput); err != nil {
 		return nil, err
 	}
-	describeStackOutput, err := cfclient.DescribeStacks(&cloudformation.DescribeStacksInput{
+	describeStackOutput, err := cfclient.DescribeStacksWithContext(ctx, &cloudformation.DescribeStacksInput{
 		StackName: createStackOutput.StackId})
 	if err != nil {
 		return nil, err
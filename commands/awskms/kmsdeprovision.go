@@ -1,6 +1,7 @@
 package awskms
 
 import (
+	"context"
 	"fmt"
 
 	"os"
@@ -29,14 +30,14 @@ func NewKmsDeprovision(c *kingpin.CmdClause) shared.Command {
 }
 
 // Run the command.
-func (w *kmsDeprovision) Run() error {
+func (w *kmsDeprovision) Run(ctx context.Context) error {
 	var failure error
 	var wg sync.WaitGroup
 	for _, region := range *w.regions {
 		wg.Add(1)
 		go func(region string) {
 			defer wg.Done()
-			if err := w.deprovisionOneRegion(region); err != nil {
+			if err := w.deprovisionOneRegion(ctx, region); err != nil {
 				fmt.Fprintf(os.Stderr, "%s: error: %s\n", region, err)
 				failure = err
 			}
@@ -50,13 +51,13 @@ func (w *kmsDeprovision) Run() error {
 	return failure
 }
 
-func (w *kmsDeprovision) deprovisionOneRegion(region string) error {
+func (w *kmsDeprovision) deprovisionOneRegion(ctx context.Context, region string) error {
 	aliasName := kmsAliasName(*w.label)
 	stackName := cfStackName(*w.label)
 	fmt.Printf("%s: Searching for label '%s'...\n", region, *w.label)
 	var foundAlias *kms.AliasListEntry
 	kmsClient := kmsHelper{kms.New(shared.GetNewSessionWithRegion(region))}
-	foundAlias, err := kmsClient.GetAliasByName(aliasName)
+	foundAlias, err := kmsClient.GetAliasByName(ctx, aliasName)
 	if err != nil {
 		return err
 	}
@@ -66,14 +67,15 @@ func (w *kmsDeprovision) deprovisionOneRegion(region string) error {
 		fmt.Printf("%s: Found alias %s for %s\n", region, aliasName, *foundAlias.TargetKeyId)
 		if *w.destructive {
 			fmt.Printf("%s: Deleting alias...\n", region)
-			if _, err := kmsClient.DeleteAlias(&kms.DeleteAliasInput{AliasName: foundAlias.AliasName}); err != nil {
+			if _, err := kmsClient.DeleteAliasWithContext(ctx,
+				&kms.DeleteAliasInput{AliasName: foundAlias.AliasName}); err != nil {
 				return err
 			}
 			fmt.Printf("%s: ... alias deleted.\n", region)
 		}
 	}
 
-	exists, err := checkCloudFormationStackExists(stackName, region)
+	exists, err := checkCloudFormationStackExists(ctx, stackName, region)
 	if err != nil {
 		return err
 	}
@@ -85,10 +87,12 @@ func (w *kmsDeprovision) deprovisionOneRegion(region string) error {
 	if *w.destructive {
 		cfclient := cloudformation.New(shared.GetNewSessionWithRegion(region))
 		fmt.Printf("%s: Deleting CloudFormation stack. This may take a while...\n", region)
-		if _, err := cfclient.DeleteStack(&cloudformation.DeleteStackInput{StackName: &stackName}); err != nil {
+		if _, err := cfclient.DeleteStackWithContext(ctx,
+			&cloudformation.DeleteStackInput{StackName: &stackName}); err != nil {
 			return err
 		}
-		if err := cfclient.WaitUntilStackDeleteComplete(&cloudformation.DescribeStacksInput{StackName: &stackName}); err != nil {
+		if err := cfclient.WaitUntilStackDeleteCompleteWithContext(ctx,
+			&cloudformation.DescribeStacksInput{StackName: &stackName}); err != nil {
 			return err
 		}
 		fmt.Printf("%s: ... stack deleted.\n", region)
@@ -1,13 +1,11 @@
 package awskms
 
 import (
+	"context"
 	"fmt"
 
 	"encoding/json"
 	"errors"
-	"io/ioutil"
-	"os"
-	"os/exec"
 	"strings"
 
 	"github.com/primait/biscuit/shared"
@@ -15,7 +13,6 @@ import (
 )
 
 var (
-	errNoEditorFound        = errors.New("Set your editor preference with VISUAL or EDITOR environment variables.")
 	errNewPolicyIsZeroBytes = errors.New("No change: the new policy is empty.")
 	errFileUnchanged        = errors.New("No change: the new policy is the same as the existing policy.")
 )
@@ -38,9 +35,9 @@ func NewKmsEditKeyPolicy(c *kingpin.CmdClause) shared.Command {
 }
 
 // Run the command.
-func (r *kmsEditKeyPolicy) Run() error {
+func (r *kmsEditKeyPolicy) Run(ctx context.Context) error {
 	aliasName := kmsAliasName(*r.label)
-	mrk, err := NewMultiRegionKey(aliasName, *r.regions, *r.forceRegion)
+	mrk, err := NewMultiRegionKey(ctx, aliasName, *r.regions, *r.forceRegion)
 	if err != nil {
 		return err
 	}
@@ -59,7 +56,7 @@ func (r *kmsEditKeyPolicy) Run() error {
 		return err
 	}
 
-	if err := mrk.SetKeyPolicy(indentedPolicy); err != nil {
+	if err := mrk.SetKeyPolicy(ctx, indentedPolicy); err != nil {
 		return err
 	}
 	fmt.Printf("New policy saved.\n")
@@ -67,32 +64,7 @@ func (r *kmsEditKeyPolicy) Run() error {
 }
 
 func launchEditor(contents string) (string, error) {
-	f, err := ioutil.TempFile("", "secrets")
-	if err != nil {
-		return "", err
-	}
-	defer os.Remove(f.Name())
-	if _, err := f.WriteString(contents); err != nil {
-		return "", err
-	}
-	if err := f.Close(); err != nil {
-		return "", err
-	}
-
-	editor, err := findEditor()
-	if err != nil {
-		return "", err
-	}
-
-	cmd := exec.Command(editor, f.Name())
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return "", err
-	}
-
-	bytes, err := ioutil.ReadFile(f.Name())
+	bytes, err := shared.LaunchEditor([]byte(contents), "policy*.json")
 	if err != nil {
 		return "", err
 	}
@@ -106,16 +78,6 @@ func launchEditor(contents string) (string, error) {
 	return newContents, nil
 }
 
-func findEditor() (string, error) {
-	for _, name := range []string{"VISUAL", "EDITOR"} {
-		candidate := os.Getenv(name)
-		if len(candidate) > 0 {
-			return candidate, nil
-		}
-	}
-	return "", errNoEditorFound
-}
-
 func prettifyJSON(content string) (string, error) {
 	var v interface{}
 	if err := json.Unmarshal([]byte(content), &v); err != nil {
@@ -2,6 +2,7 @@ package awskms
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha1"
 	"encoding/gob"
 	"encoding/hex"
@@ -54,20 +55,23 @@ type grantDetails struct {
 }
 
 // Run runs the command.
-func (w *kmsGrantsCreate) Run() error {
-	database := store.NewFileStore(*w.filename)
+func (w *kmsGrantsCreate) Run(ctx context.Context) error {
+	database, err := store.Open(*w.filename)
+	if err != nil {
+		return err
+	}
 	values, err := database.Get(*w.name)
 	if err != nil {
 		return err
 	}
 	values = values.FilterByKeyManager(keymanager.KmsLabel)
 
-	aliases, err := resolveValuesToAliasesAndRegions(values)
+	aliases, err := resolveValuesToAliasesAndRegions(ctx, values)
 	if err != nil {
 		return err
 	}
 
-	granteeArn, retireeArn, err := resolveGranteeArns(*w.granteePrincipal, *w.retiringPrincipal)
+	granteeArn, retireeArn, err := resolveGranteeArns(ctx, *w.granteePrincipal, *w.retiringPrincipal)
 
 	// The template from which grants in each region are created.
 	createGrantInput := kms.CreateGrantInput{
@@ -83,7 +87,7 @@ func (w *kmsGrantsCreate) Run() error {
 		createGrantInput.RetiringPrincipal = &retireeArn
 	}
 
-	grantName, err := computeGrantName(createGrantInput)
+	grantName, err := computeGrantName(ctx, createGrantInput)
 	if err != nil {
 		return err
 	}
@@ -94,11 +98,11 @@ func (w *kmsGrantsCreate) Run() error {
 		Aliases: make(map[string]map[string]grantDetails),
 	}
 	for alias, regionList := range aliases {
-		mrk, err := NewMultiRegionKey(alias, regionList, "")
+		mrk, err := NewMultiRegionKey(ctx, alias, regionList, "")
 		if err != nil {
 			return err
 		}
-		results, err := mrk.AddGrant(createGrantInput)
+		results, err := mrk.AddGrant(ctx, createGrantInput)
 		if err != nil {
 			return err
 		}
@@ -114,8 +118,8 @@ func (w *kmsGrantsCreate) Run() error {
 	return nil
 }
 
-func computeGrantName(input kms.CreateGrantInput) (string, error) {
-	callerIdentity, err := sts.New(shared.GetNewSession()).GetCallerIdentity(nil)
+func computeGrantName(ctx context.Context, input kms.CreateGrantInput) (string, error) {
+	callerIdentity, err := sts.New(shared.GetNewSession()).GetCallerIdentityWithContext(ctx, nil)
 	if err != nil {
 		return "", err
 	}
@@ -130,7 +134,7 @@ func computeGrantName(input kms.CreateGrantInput) (string, error) {
 	return GrantPrefix + hex.EncodeToString(hashed[:])[:10], nil
 }
 
-func resolveValuesToAliasesAndRegions(values store.ValueList) (map[string][]string, error) {
+func resolveValuesToAliasesAndRegions(ctx context.Context, values store.ValueList) (map[string][]string, error) {
 	// The KeyID field may refer to a key/ or alias/ ARN. We need to resolve the alias for any key/ ARN
 	// so that we can act on them across multiple regions. This loop resolves key/ ARNs into their appropriate
 	// aliases, and maintains a list of regions for each alias.
@@ -144,7 +148,7 @@ func resolveValuesToAliasesAndRegions(values store.ValueList) (map[string][]stri
 			aliases["alias/"+arn.Resource] = append(aliases["alias/"+arn.Resource], arn.Region)
 		} else if arn.IsKmsKey() {
 			client := kmsHelper{kms.New(shared.GetNewSessionWithRegion(arn.Region))}
-			alias, err := client.GetAliasByKeyID(arn.Resource)
+			alias, err := client.GetAliasByKeyID(ctx, arn.Resource)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%s: Unable to find an alias for this key: %s\n", v.KeyID, err)
 				return nil, err
@@ -157,9 +161,9 @@ func resolveValuesToAliasesAndRegions(values store.ValueList) (map[string][]stri
 	return aliases, nil
 }
 
-func resolveGranteeArns(granteePrincipal, retiringPrincipal string) (string, string, error) {
+func resolveGranteeArns(ctx context.Context, granteePrincipal, retiringPrincipal string) (string, string, error) {
 	stsClient := sts.New(shared.GetNewSession())
-	callerIdentity, err := stsClient.GetCallerIdentity(nil)
+	callerIdentity, err := stsClient.GetCallerIdentityWithContext(ctx, nil)
 	if err != nil {
 		return "", "", err
 	}
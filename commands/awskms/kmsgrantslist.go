@@ -1,6 +1,7 @@
 package awskms
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/primait/biscuit/keymanager"
@@ -30,26 +31,29 @@ type grantsForOneAlias struct {
 }
 
 // Run runs the command.
-func (w *kmsGrantsList) Run() error {
-	database := store.NewFileStore(*w.filename)
+func (w *kmsGrantsList) Run(ctx context.Context) error {
+	database, err := store.Open(*w.filename)
+	if err != nil {
+		return err
+	}
 	values, err := database.Get(*w.name)
 	if err != nil {
 		return err
 	}
 	values = values.FilterByKeyManager(keymanager.KmsLabel)
 
-	aliases, err := resolveValuesToAliasesAndRegions(values)
+	aliases, err := resolveValuesToAliasesAndRegions(ctx, values)
 	if err != nil {
 		return err
 	}
 
 	output := make(map[string]map[string]grantsForOneAlias)
 	for aliasName, regions := range aliases {
-		mrk, err := NewMultiRegionKey(aliasName, regions, "")
+		mrk, err := NewMultiRegionKey(ctx, aliasName, regions, "")
 		if err != nil {
 			return err
 		}
-		regionGrants, err := mrk.GetGrantDetails()
+		regionGrants, err := mrk.GetGrantDetails(ctx)
 		if err != nil {
 			return err
 		}
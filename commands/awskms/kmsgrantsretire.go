@@ -1,6 +1,7 @@
 package awskms
 
 import (
+	"context"
 	"github.com/primait/biscuit/keymanager"
 	"github.com/primait/biscuit/shared"
 	"github.com/primait/biscuit/store"
@@ -20,26 +21,29 @@ func NewKmsGrantsRetire(c *kingpin.CmdClause) shared.Command {
 	}
 }
 
-func (w *kmsGrantsRetire) Run() error {
-	database := store.NewFileStore(*w.filename)
+func (w *kmsGrantsRetire) Run(ctx context.Context) error {
+	database, err := store.Open(*w.filename)
+	if err != nil {
+		return err
+	}
 	values, err := database.Get(*w.name)
 	if err != nil {
 		return err
 	}
 	values = values.FilterByKeyManager(keymanager.KmsLabel)
 
-	aliases, err := resolveValuesToAliasesAndRegions(values)
+	aliases, err := resolveValuesToAliasesAndRegions(ctx, values)
 	if err != nil {
 		return err
 	}
 
 	for aliasName, regions := range aliases {
-		mrk, err := NewMultiRegionKey(aliasName, regions, "")
+		mrk, err := NewMultiRegionKey(ctx, aliasName, regions, "")
 		if err != nil {
 			return err
 		}
 
-		if err := mrk.RetireGrant(*w.grantName); err != nil {
+		if err := mrk.RetireGrant(ctx, *w.grantName); err != nil {
 			return err
 		}
 	}
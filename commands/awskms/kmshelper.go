@@ -1,6 +1,7 @@
 package awskms
 
 import (
+	"context"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -11,9 +12,9 @@ type kmsHelper struct {
 	*kms.KMS
 }
 
-func (k *kmsHelper) GetAliasByName(aliasName string) (*kms.AliasListEntry, error) {
+func (k *kmsHelper) GetAliasByName(ctx context.Context, aliasName string) (*kms.AliasListEntry, error) {
 	var foundAlias *kms.AliasListEntry
-	err := k.ListAliasesPages(nil, func(input *kms.ListAliasesOutput, _ bool) bool {
+	err := k.ListAliasesPagesWithContext(ctx, nil, func(input *kms.ListAliasesOutput, _ bool) bool {
 		for _, alias := range input.Aliases {
 			if *alias.AliasName == aliasName {
 				foundAlias = alias
@@ -26,9 +27,9 @@ func (k *kmsHelper) GetAliasByName(aliasName string) (*kms.AliasListEntry, error
 	return foundAlias, err
 }
 
-func (k *kmsHelper) GetAliasByKeyID(keyID string) (string, error) {
+func (k *kmsHelper) GetAliasByKeyID(ctx context.Context, keyID string) (string, error) {
 	var foundAlias *kms.AliasListEntry
-	err := k.ListAliasesPages(nil, func(input *kms.ListAliasesOutput, _ bool) bool {
+	err := k.ListAliasesPagesWithContext(ctx, nil, func(input *kms.ListAliasesOutput, _ bool) bool {
 		for _, alias := range input.Aliases {
 			if strings.HasPrefix(*alias.AliasName, AliasPrefix) && *alias.TargetKeyId == keyID {
 				foundAlias = alias
@@ -43,15 +44,15 @@ func (k *kmsHelper) GetAliasByKeyID(keyID string) (string, error) {
 	return "", &errNoAliasFoundForKey{keyID}
 }
 
-func (k *kmsHelper) GetAliasTargetAndPolicy(aliasName string) (string, string, error) {
-	alias, err := k.GetAliasByName(aliasName)
+func (k *kmsHelper) GetAliasTargetAndPolicy(ctx context.Context, aliasName string) (string, string, error) {
+	alias, err := k.GetAliasByName(ctx, aliasName)
 	if err != nil {
 		return "", "", err
 	}
 	if alias == nil {
 		return "", "", &errAliasNotFound{aliasName}
 	}
-	policyOutput, err := k.GetKeyPolicy(&kms.GetKeyPolicyInput{KeyId: alias.TargetKeyId,
+	policyOutput, err := k.GetKeyPolicyWithContext(ctx, &kms.GetKeyPolicyInput{KeyId: alias.TargetKeyId,
 		PolicyName: aws.String("default")})
 	if err != nil {
 		return "", "", err
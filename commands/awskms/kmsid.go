@@ -1,6 +1,7 @@
 package awskms
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/aws/aws-sdk-go/service/sts"
@@ -11,7 +12,7 @@ import (
 type KmsGetCallerIdentity struct{}
 
 // Run prints the results of STS GetCallerIdentity.
-func (w *KmsGetCallerIdentity) Run() error {
+func (w *KmsGetCallerIdentity) Run(ctx context.Context) error {
 	session := shared.GetNewSession()
 	credentials, err := session.Config.Credentials.Get()
 	if err != nil {
@@ -22,7 +23,7 @@ func (w *KmsGetCallerIdentity) Run() error {
 	fmt.Printf("AWS Access Key: %s\n", credentials.AccessKeyID)
 	fmt.Printf("# STS GetCallerIdentity\n")
 	stsClient := sts.New(session)
-	getCallerIdentityOutput, err := stsClient.GetCallerIdentity(nil)
+	getCallerIdentityOutput, err := stsClient.GetCallerIdentityWithContext(ctx, nil)
 	if err != nil {
 		return err
 	}
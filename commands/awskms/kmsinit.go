@@ -1,6 +1,7 @@
 package awskms
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -80,13 +81,16 @@ func NewKmsInit(c *kingpin.CmdClause, keyCloudformationTemplate string) shared.C
 }
 
 // Run runs the command.
-func (w *kmsInit) Run() error {
-	regionKeys, err := w.discoverOrCreateKeys()
+func (w *kmsInit) Run(ctx context.Context) error {
+	regionKeys, err := w.discoverOrCreateKeys(ctx)
 	if err != nil {
 		return err
 	}
 
-	database := store.NewFileStore(*w.filename)
+	database, err := store.Open(*w.filename)
+	if err != nil {
+		return err
+	}
 
 	// If the file exists, we'll make changes to its template rather than replace it.
 	keyConfigs, err := database.Get(store.KeyTemplateName)
@@ -127,7 +131,8 @@ func (w *kmsInit) Run() error {
 	return database.Put(store.KeyTemplateName, updatedTemplate)
 }
 
-func collectRegionInfo(stackName, keyAlias string, regions []string) (map[string]string, []string, error) {
+func collectRegionInfo(ctx context.Context, stackName, keyAlias string, regions []string) (map[string]string,
+	[]string, error) {
 	regionErrors := make(map[string][]error)
 	regionKeys := make(map[string]string)
 	var regionsMissing []string
@@ -135,13 +140,13 @@ func collectRegionInfo(stackName, keyAlias string, regions []string) (map[string
 	for _, region := range regions {
 		var keyExists, stackExists bool
 
-		if exists, err := checkCloudFormationStackExists(stackName, region); err != nil {
+		if exists, err := checkCloudFormationStackExists(ctx, stackName, region); err != nil {
 			regionErrors[region] = append(regionErrors[region], err)
 		} else {
 			stackExists = exists
 		}
 
-		if regionKey, err := checkKmsKeyExists(keyAlias, region); err != nil {
+		if regionKey, err := checkKmsKeyExists(ctx, keyAlias, region); err != nil {
 			regionErrors[region] = append(regionErrors[region], err)
 		} else if len(regionKey) > 0 {
 			keyExists = true
@@ -172,9 +177,9 @@ func collectRegionInfo(stackName, keyAlias string, regions []string) (map[string
 	return regionKeys, regionsMissing, err
 }
 
-func checkCloudFormationStackExists(stackName, region string) (bool, error) {
+func checkCloudFormationStackExists(ctx context.Context, stackName, region string) (bool, error) {
 	cfclient := cloudformation.New(shared.GetNewSessionWithRegion(region))
-	_, err := cfclient.DescribeStacks(&cloudformation.DescribeStacksInput{
+	_, err := cfclient.DescribeStacksWithContext(ctx, &cloudformation.DescribeStacksInput{
 		StackName: aws.String(stackName),
 	})
 	if err == nil {
@@ -189,7 +194,7 @@ func checkCloudFormationStackExists(stackName, region string) (bool, error) {
 	return false, fmt.Errorf("%s", err)
 }
 
-func checkKmsKeyExists(keyAlias, region string) (string, error) {
+func checkKmsKeyExists(ctx context.Context, keyAlias, region string) (string, error) {
 	var foundAliasArn string
 	kmsClient := kms.New(shared.GetNewSessionWithRegion(region))
 	var callbackErr error
@@ -198,7 +203,8 @@ func checkKmsKeyExists(keyAlias, region string) (string, error) {
 			if *aliasRecord.AliasName != keyAlias {
 				continue
 			}
-			keyDetails, err := kmsClient.DescribeKey(&kms.DescribeKeyInput{KeyId: aliasRecord.TargetKeyId})
+			keyDetails, err := kmsClient.DescribeKeyWithContext(ctx,
+				&kms.DescribeKeyInput{KeyId: aliasRecord.TargetKeyId})
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "DescribeKey failed: %s", err)
 				callbackErr = err
@@ -218,13 +224,13 @@ func checkKmsKeyExists(keyAlias, region string) (string, error) {
 		}
 		return true
 	}
-	if err := kmsClient.ListAliasesPages(nil, fp); err != nil {
+	if err := kmsClient.ListAliasesPagesWithContext(ctx, nil, fp); err != nil {
 		return foundAliasArn, err
 	}
 	return foundAliasArn, callbackErr
 }
 
-func (w *kmsInit) discoverOrCreateKeys() (map[string]string, error) {
+func (w *kmsInit) discoverOrCreateKeys(ctx context.Context) (map[string]string, error) {
 	fmt.Printf("Checking %s for the '%s' label.\n",
 		friendlyJoin(*w.regions),
 		*w.label)
@@ -232,7 +238,7 @@ func (w *kmsInit) discoverOrCreateKeys() (map[string]string, error) {
 	aliasName := kmsAliasName(*w.label)
 	stackName := cfStackName(*w.label)
 
-	existingAliases, regionsMissingKeys, err := collectRegionInfo(stackName, aliasName, *w.regions)
+	existingAliases, regionsMissingKeys, err := collectRegionInfo(ctx, stackName, aliasName, *w.regions)
 	if err != nil {
 		return nil, err
 	}
@@ -251,7 +257,7 @@ func (w *kmsInit) discoverOrCreateKeys() (map[string]string, error) {
 		fmt.Printf("Found %d pre-existing keys.\n", len(existingAliases))
 	}
 	if len(existingAliases) == 0 || *w.createMissingKeys {
-		finalAdminArns, finalUserArns, err := w.constructArns()
+		finalAdminArns, finalUserArns, err := w.constructArns(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -267,7 +273,7 @@ func (w *kmsInit) discoverOrCreateKeys() (map[string]string, error) {
 				defer wg.Done()
 				started := time.Now()
 				fmt.Printf("%s: Creating resources using CloudFormation. This may take a while.\n", region)
-				existingAliases[region], err = w.createKeyInRegion(region, stackName,
+				existingAliases[region], err = w.createKeyInRegion(ctx, region, stackName,
 					aliasName, finalAdminArns, finalUserArns)
 				if err != nil {
 					errs <- fmt.Errorf("%s: %s", region, err)
@@ -288,7 +294,8 @@ func (w *kmsInit) discoverOrCreateKeys() (map[string]string, error) {
 }
 
 // createKeyInRegion creates a key for a region and returns the Alias's ARN.
-func (w *kmsInit) createKeyInRegion(region, stackName, aliasName string, finalAdminArns, finalUserArns []string) (string, error) {
+func (w *kmsInit) createKeyInRegion(ctx context.Context, region, stackName, aliasName string, finalAdminArns,
+	finalUserArns []string) (string, error) {
 	specs := cloudformationStack{
 		params: map[string]string{
 			"AdministratorPrincipals":            strings.Join(finalAdminArns, ","),
@@ -305,7 +312,7 @@ func (w *kmsInit) createKeyInRegion(region, stackName, aliasName string, finalAd
 	} else {
 		specs.templateBody = &w.keyCloudformationTemplate
 	}
-	outputs, err := specs.createAndWait()
+	outputs, err := specs.createAndWait(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -314,20 +321,20 @@ func (w *kmsInit) createKeyInRegion(region, stackName, aliasName string, finalAd
 		return "", fmt.Errorf("Stack %s does not have an Output named KeyArn.", stackName)
 	}
 
-	aliasARN, err := createAlias(region, aliasName, keyArn)
+	aliasARN, err := createAlias(ctx, region, aliasName, keyArn)
 	return aliasARN, err
 }
 
-func createAlias(region, aliasName, keyArn string) (string, error) {
+func createAlias(ctx context.Context, region, aliasName, keyArn string) (string, error) {
 	fmt.Printf("%s: creating alias '%s' for key %s.\n", region, aliasName, keyArn)
 	client := kmsHelper{kms.New(shared.GetNewSessionWithRegion(region))}
-	if _, err := client.CreateAlias(&kms.CreateAliasInput{
+	if _, err := client.CreateAliasWithContext(ctx, &kms.CreateAliasInput{
 		TargetKeyId: aws.String(keyArn),
 		AliasName:   aws.String(aliasName)}); err != nil {
 		return "", err
 	}
 	fmt.Printf("%s: fetching ARN for the new alias.\n", region)
-	aliasListEntry, err := client.GetAliasByName(aliasName)
+	aliasListEntry, err := client.GetAliasByName(ctx, aliasName)
 	if err != nil {
 		return "", err
 	}
@@ -344,9 +351,9 @@ func truefalse(iff bool) string {
 	return "false"
 }
 
-func (w *kmsInit) constructArns() ([]string, []string, error) {
+func (w *kmsInit) constructArns(ctx context.Context) ([]string, []string, error) {
 	stsClient := sts.New(shared.GetNewSession())
-	callerIdentity, err := stsClient.GetCallerIdentity(nil)
+	callerIdentity, err := stsClient.GetCallerIdentityWithContext(ctx, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -1,6 +1,7 @@
 package awskms
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -32,7 +33,8 @@ func (r *regionSpecificInfo) Error() string {
 }
 
 // NewMultiRegionKey constructs a MultiRegionKey.
-func NewMultiRegionKey(aliasName string, regions []string, forceRegion string) (*MultiRegionKey, error) {
+func NewMultiRegionKey(ctx context.Context, aliasName string, regions []string, forceRegion string) (*MultiRegionKey,
+	error) {
 	mrk := &MultiRegionKey{aliasName: aliasName, regions: regions, regionToID: make(map[string]string)}
 	results := make(chan regionSpecificInfo, len(regions))
 	var wg sync.WaitGroup
@@ -42,7 +44,7 @@ func NewMultiRegionKey(aliasName string, regions []string, forceRegion string) (
 			defer wg.Done()
 			output := regionSpecificInfo{region: region}
 			client := kmsHelper{kms.New(shared.GetNewSessionWithRegion(region))}
-			keyID, policy, err := client.GetAliasTargetAndPolicy(aliasName)
+			keyID, policy, err := client.GetAliasTargetAndPolicy(ctx, aliasName)
 			if err != nil {
 				output.err = err
 			} else {
@@ -89,7 +91,7 @@ func NewMultiRegionKey(aliasName string, regions []string, forceRegion string) (
 }
 
 // SetKeyPolicy sets a new Key Policy.
-func (m *MultiRegionKey) SetKeyPolicy(policy string) error {
+func (m *MultiRegionKey) SetKeyPolicy(ctx context.Context, policy string) error {
 	errs := make(regionErrorCollector, len(m.regions))
 	var wg sync.WaitGroup
 	for _, region := range m.regions {
@@ -97,7 +99,7 @@ func (m *MultiRegionKey) SetKeyPolicy(policy string) error {
 		go func(region string) {
 			defer wg.Done()
 			client := kmsHelper{kms.New(shared.GetNewSessionWithRegion(region))}
-			if _, err := client.PutKeyPolicy(&kms.PutKeyPolicyInput{
+			if _, err := client.PutKeyPolicyWithContext(ctx, &kms.PutKeyPolicyInput{
 				KeyId:      aws.String(m.regionToID[region]),
 				PolicyName: aws.String("default"),
 				Policy:     &policy}); err != nil {
@@ -116,7 +118,7 @@ type getGrantsResults struct {
 }
 
 // GetGrantDetails returns a list of grants for each region.
-func (m *MultiRegionKey) GetGrantDetails() (map[string][]*kms.GrantListEntry, error) {
+func (m *MultiRegionKey) GetGrantDetails(ctx context.Context) (map[string][]*kms.GrantListEntry, error) {
 	errs := make(regionErrorCollector, len(m.regions))
 	allGrants := make(chan getGrantsResults, len(m.regions))
 	var wg sync.WaitGroup
@@ -126,7 +128,7 @@ func (m *MultiRegionKey) GetGrantDetails() (map[string][]*kms.GrantListEntry, er
 			defer wg.Done()
 			client := kmsHelper{kms.New(shared.GetNewSessionWithRegion(region))}
 			var grants []*kms.GrantListEntry
-			if err := client.ListGrantsPages(
+			if err := client.ListGrantsPagesWithContext(ctx,
 				&kms.ListGrantsInput{KeyId: aws.String(m.regionToID[region])},
 				func(p *kms.ListGrantsResponse, last bool) bool {
 					for _, grant := range p.Grants {
@@ -164,7 +166,8 @@ type addGrantResults struct {
 }
 
 // AddGrant adds a grant to all of the underlying regions. Returns a map of region -> grant.
-func (m *MultiRegionKey) AddGrant(grant kms.CreateGrantInput) (map[string]kms.CreateGrantOutput, error) {
+func (m *MultiRegionKey) AddGrant(ctx context.Context, grant kms.CreateGrantInput) (map[string]kms.CreateGrantOutput,
+	error) {
 	results := make(chan addGrantResults, len(m.regions))
 	var wg sync.WaitGroup
 	for _, region := range m.regions {
@@ -173,7 +176,7 @@ func (m *MultiRegionKey) AddGrant(grant kms.CreateGrantInput) (map[string]kms.Cr
 			defer wg.Done()
 			grant.KeyId = aws.String(m.regionToID[region])
 			kmsClient := kms.New(shared.GetNewSessionWithRegion(region))
-			createGrantOutput, err := kmsClient.CreateGrant(&grant)
+			createGrantOutput, err := kmsClient.CreateGrantWithContext(ctx, &grant)
 			if err != nil {
 				results <- addGrantResults{region: region, err: err}
 				return
@@ -196,7 +199,7 @@ func (m *MultiRegionKey) AddGrant(grant kms.CreateGrantInput) (map[string]kms.Cr
 }
 
 // RetireGrant retires a grant in all regions.
-func (m *MultiRegionKey) RetireGrant(name string) error {
+func (m *MultiRegionKey) RetireGrant(ctx context.Context, name string) error {
 	results := make(regionErrorCollector, len(m.regions))
 	var wg sync.WaitGroup
 	for _, region := range m.regions {
@@ -206,7 +209,7 @@ func (m *MultiRegionKey) RetireGrant(name string) error {
 			kmsClient := kms.New(shared.GetNewSessionWithRegion(region))
 			// Find GrantID in this region
 			var grantID *string
-			if err := kmsClient.ListGrantsPages(&kms.ListGrantsInput{
+			if err := kmsClient.ListGrantsPagesWithContext(ctx, &kms.ListGrantsInput{
 				KeyId: aws.String(m.regionToID[region])},
 				func(p *kms.ListGrantsResponse, last bool) bool {
 					for _, grant := range p.Grants {
@@ -226,7 +229,7 @@ func (m *MultiRegionKey) RetireGrant(name string) error {
 			}
 
 			// Revoke by GrantID
-			_, err := kmsClient.RevokeGrant(&kms.RevokeGrantInput{KeyId: aws.String(m.regionToID[region]),
+			_, err := kmsClient.RevokeGrantWithContext(ctx, &kms.RevokeGrantInput{KeyId: aws.String(m.regionToID[region]),
 				GrantId: grantID})
 			results <- regionError{Region: region, Err: err}
 		}(region)
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/primait/biscuit/store"
+)
+
+// decryptSecrets decrypts the named secrets of the store at location, through the agent if there is one or
+// else in parallel. It returns the plaintexts and the errors of the secrets that could not be decrypted.
+func decryptSecrets(ctx context.Context, location string, entries store.EntryMap, names []string,
+	regionPriority []string, parallelism int) (map[string][]byte, map[string]error) {
+	if plaintexts, errs, ok := agentDecrypt(ctx, location, names, regionPriority); ok {
+		return plaintexts, errs
+	}
+	return decryptLocally(ctx, entries, names, regionPriority, parallelism)
+}
+
+// decryptEntries decrypts the named secrets in parallel without the agent. It fails if any of them cannot be
+// decrypted.
+func decryptEntries(ctx context.Context, entries store.EntryMap, names []string, regionPriority []string,
+	parallelism int) (map[string][]byte, error) {
+	for _, name := range names {
+		if _, present := entries[name]; !present || name == store.KeyTemplateName {
+			return nil, fmt.Errorf("%s: %s", name, store.ErrNameNotFound)
+		}
+	}
+	plaintexts, errs := decryptLocally(ctx, entries, names, regionPriority, parallelism)
+	if err := firstFailure(names, errs); err != nil {
+		return nil, err
+	}
+	return plaintexts, nil
+}
+
+// decryptLocally decrypts the named secrets in parallel, trying the values of each in order of region
+// priority.
+func decryptLocally(ctx context.Context, entries store.EntryMap, names []string, regionPriority []string,
+	parallelism int) (map[string][]byte, map[string]error) {
+	var mu sync.Mutex
+	plaintexts := make(map[string][]byte)
+	errs := make(map[string]error)
+	sortByRegion := store.SortByKmsRegion(regionPriority)
+	forEachParallel(names, parallelism, func(name string) {
+		values := append(store.ValueList{}, entries[name]...)
+		sortByRegion(values)
+		plaintext, err := decryptAnyValue(ctx, values, name)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs[name] = err
+			return
+		}
+		plaintexts[name] = plaintext
+	})
+	return plaintexts, errs
+}
+
+// firstFailure returns the error of the first of names that could not be decrypted, or nil.
+func firstFailure(names []string, errs map[string]error) error {
+	for _, name := range names {
+		if err, present := errs[name]; present {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+	}
+	return nil
+}
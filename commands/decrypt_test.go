@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecryptSecrets(t *testing.T) {
+	entries := store.EntryMap{
+		"password": {{Key: store.Key{Algorithm: "none"}, Ciphertext: "Z29k"}},
+		"corrupt":  {{Key: store.Key{Algorithm: "none"}, Ciphertext: "!"}},
+	}
+	plaintexts, errs := decryptSecrets(context.Background(), "secrets.yml", entries,
+		[]string{"corrupt", "password"}, nil, 2)
+	assert.Equal(t, map[string][]byte{"password": []byte("god")}, plaintexts)
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, firstFailure([]string{"corrupt", "password"}, errs),
+		"corrupt: illegal base64 data at input byte 0")
+	assert.NoError(t, firstFailure([]string{"password"}, errs))
+
+	_, err := decryptEntries(context.Background(), entries, []string{"password", "corrupt"}, nil, 2)
+	assert.EqualError(t, err, "corrupt: illegal base64 data at input byte 0")
+	_, err = decryptEntries(context.Background(), entries, []string{"missing"}, nil, 2)
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/primait/biscuit/shared"
+	"github.com/primait/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var errCannotDeleteTemplate = errors.New("The " + store.KeyTemplateName + " entry configures the keys used for " +
+	"new secrets and cannot be deleted with this command.")
+
+type deleteSecrets struct {
+	names    *[]string
+	filename *string
+}
+
+// NewDelete configures the command to delete secrets.
+func NewDelete(c *kingpin.CmdClause) shared.Command {
+	return &deleteSecrets{
+		names:    c.Arg("name", "Names of the secrets to delete.").Required().Strings(),
+		filename: shared.FilenameFlag(c),
+	}
+}
+
+// Run the command.
+func (r *deleteSecrets) Run(ctx context.Context) error {
+	for _, name := range *r.names {
+		if name == store.KeyTemplateName {
+			return errCannotDeleteTemplate
+		}
+	}
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	entries, version, err := database.GetAllVersion()
+	if err != nil {
+		return err
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range *r.names {
+		if _, present := entries[name]; !present {
+			return fmt.Errorf("%s: %s", name, store.ErrNameNotFound)
+		}
+		if !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	// Delete every name in one write, so that a failure leaves all of them in place.
+	return database.PutAllIfVersion(version, nil, names...)
+}
@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/primait/biscuit/shared"
+	"github.com/primait/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	errEditNameOrAll  = errors.New("Please specify either a secret name or --all, but not both.")
+	errEditNoChange   = errors.New("No change: the secrets are the same as before.")
+	errEditNoTemplate = errors.New("The file you've specified does not exist. Please create a file with " +
+		"kms init or put a secret with --key-id first.")
+)
+
+type edit struct {
+	name, filename *string
+	all            *bool
+	regionPriority *[]string
+	parallelism    *int
+}
+
+// NewEdit configures the command to edit secrets in a text editor.
+func NewEdit(c *kingpin.CmdClause) shared.Command {
+	return &edit{
+		name:           c.Arg("name", "Name of the secret to edit. It is created if it does not exist.").String(),
+		all:            c.Flag("all", "Edit every secret at once as a plaintext YAML document.").Bool(),
+		regionPriority: shared.AwsRegionPriorityFlag(c),
+		parallelism:    shared.ParallelismFlag(c),
+		filename:       shared.FilenameFlag(c),
+	}
+}
+
+// Run the command.
+func (r *edit) Run(ctx context.Context) error {
+	if *r.all == (len(*r.name) > 0) {
+		return errEditNameOrAll
+	}
+	if *r.name == store.KeyTemplateName {
+		return fmt.Errorf("the %s entry cannot be edited", store.KeyTemplateName)
+	}
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	entries, version, err := database.GetAllVersion()
+	if os.IsNotExist(err) {
+		return errEditNoTemplate
+	}
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	if *r.all {
+		names = entries.SecretNames()
+	} else if _, present := entries[*r.name]; present {
+		names = []string{*r.name}
+	}
+	originals, err := decryptEntries(ctx, entries, names, *r.regionPriority, *r.parallelism)
+	if err != nil {
+		return err
+	}
+
+	var edited map[string][]byte
+	if *r.all {
+		edited, err = editDocument(originals)
+	} else {
+		edited, err = editValue(*r.name, originals[*r.name])
+		if _, present := originals[*r.name]; !present && err == nil && len(edited[*r.name]) == 0 {
+			return errEditNoChange
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	changed, removed := diffSecrets(originals, edited)
+	for _, name := range removed {
+		fmt.Fprintf(os.Stderr, "Warning: %s was removed in the editor but has not been deleted. "+
+			"Use %s delete to delete it.\n", name, shared.ProgName)
+	}
+	if len(changed) == 0 {
+		return errEditNoChange
+	}
+
+	var template []store.Key
+	for _, name := range changed {
+		if _, present := entries[name]; !present {
+			if template, err = database.GetKeyIds(); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	author := currentAuthor(ctx, editKeys(entries[changed[0]], template))
+	var mu sync.Mutex
+	encrypted := make(store.EntryMap)
+	var errs []error
+	forEachParallel(changed, *r.parallelism, func(name string) {
+		valueList, err := encryptAll(ctx, editKeys(entries[name], template), name, edited[name])
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", name, err))
+			return
+		}
+		encrypted[name] = setMetadata(valueList, touchMetadata(entries[name].Metadata(), author))
+	})
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+		return fmt.Errorf("%d of %d secrets could not be encrypted; nothing was changed", len(errs),
+			len(changed))
+	}
+
+	if err := database.PutAllIfVersion(version, encrypted); err != nil {
+		return err
+	}
+	for _, name := range changed {
+		action := "updated"
+		if _, present := originals[name]; !present {
+			action = "created"
+		}
+		fmt.Printf("%s: %s (%s)\n", name, action, describeValues(encrypted[name]))
+	}
+	return nil
+}
+
+// editValue opens a single plaintext value in the editor. Editors usually end the file with a newline, so
+// if the original value did not end with one, a single trailing newline is removed from the result.
+func editValue(name string, plaintext []byte) (map[string][]byte, error) {
+	contents, err := shared.LaunchEditor(plaintext, shared.ProgName+"-*")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasSuffix(plaintext, []byte("\n")) {
+		contents = bytes.TrimSuffix(contents, []byte("\n"))
+	}
+	return map[string][]byte{name: contents}, nil
+}
+
+// editDocument opens every plaintext value in the editor as a YAML document of names to values.
+func editDocument(plaintexts map[string][]byte) (map[string][]byte, error) {
+	document := make(map[string]string)
+	for name, plaintext := range plaintexts {
+		document[name] = string(plaintext)
+	}
+	contents, err := shared.LaunchEditor([]byte(shared.MustYaml(document)), shared.ProgName+"-*.yml")
+	if err != nil {
+		return nil, err
+	}
+	var edited map[string]string
+	if err := yaml.Unmarshal(contents, &edited); err != nil {
+		return nil, fmt.Errorf("the edited document is not valid YAML: %s", err)
+	}
+	secrets := make(map[string][]byte)
+	for name, value := range edited {
+		if name == store.KeyTemplateName {
+			return nil, fmt.Errorf("the %s entry cannot be edited", store.KeyTemplateName)
+		}
+		secrets[name] = []byte(value)
+	}
+	return secrets, nil
+}
+
+// diffSecrets returns the sorted names of the secrets in edited that are new or differ from originals, and
+// the sorted names of the secrets in originals that are missing from edited.
+func diffSecrets(originals, edited map[string][]byte) ([]string, []string) {
+	var changed, removed []string
+	for name, plaintext := range edited {
+		if original, present := originals[name]; !present || !bytes.Equal(original, plaintext) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range originals {
+		if _, present := edited[name]; !present {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// editKeys returns the keys to encrypt an edited secret under: the keys of its existing values, so that
+// editing does not change where a secret can be decrypted, or the key template if the secret is new.
+func editKeys(values store.ValueList, template []store.Key) []store.Key {
+	if len(values) == 0 {
+		return template
+	}
+	var keys []store.Key
+	for _, value := range values {
+		keys = append(keys, value.Key)
+	}
+	return keys
+}
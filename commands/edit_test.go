@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSecrets(t *testing.T) {
+	originals := map[string][]byte{
+		"same":    []byte("a"),
+		"changed": []byte("b"),
+		"removed": []byte("c"),
+	}
+	edited := map[string][]byte{
+		"same":    []byte("a"),
+		"changed": []byte("B"),
+		"added":   []byte(""),
+	}
+	changed, removed := diffSecrets(originals, edited)
+	assert.Equal(t, []string{"added", "changed"}, changed)
+	assert.Equal(t, []string{"removed"}, removed)
+}
+
+func TestEditKeys(t *testing.T) {
+	template := []store.Key{{KeyManager: "aws", KeyID: "template"}}
+	assert.Equal(t, template, editKeys(nil, template))
+
+	values := store.ValueList{
+		{Key: store.Key{KeyManager: "aws", KeyID: "us-west-1"}},
+		{Key: store.Key{KeyManager: "aws", KeyID: "us-west-2"}},
+	}
+	assert.Equal(t, []store.Key{values[0].Key, values[1].Key}, editKeys(values, template))
+}
@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/primait/biscuit/shared"
+	"github.com/primait/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	transformUpper = "upper"
+	transformNone  = "none"
+)
+
+type errDuplicateEnvName struct {
+	envName, first, second string
+}
+
+func (e *errDuplicateEnvName) Error() string {
+	return fmt.Sprintf("Secrets '%s' and '%s' would both be stored in $%s. Use --only to choose one.",
+		e.first, e.second, e.envName)
+}
+
+// ExitStatus is returned by exec when the command runs as a child process and fails, so that biscuit can
+// clean up before exiting with the command's status.
+type ExitStatus int
+
+func (e ExitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+type execCommand struct {
+	filename       *string
+	regionPriority *[]string
+	only, exclude  *[]string
+	prefix         *string
+	transform      *string
+	parallelism    *int
+	command        *[]string
+}
+
+// NewExec configures the command to run a process with secrets in its environment.
+func NewExec(c *kingpin.CmdClause) shared.Command {
+	params := &execCommand{}
+	params.filename = shared.FilenameFlag(c)
+	params.regionPriority = shared.AwsRegionPriorityFlag(c)
+	params.only = c.Flag("only", "Pass only the secrets whose names match PATTERN (ex: 'db-*') to the process. "+
+		"May be repeated or separated by commas. If not set, all secrets are passed.").
+		PlaceHolder("PATTERN").
+		Strings()
+	params.exclude = c.Flag("exclude", "Do not pass secrets whose names match PATTERN. May be repeated or "+
+		"separated by commas.").
+		PlaceHolder("PATTERN").
+		Strings()
+	params.prefix = c.Flag("prefix", "Prefix added to each environment variable name, ex: APP_.").String()
+	params.transform = c.Flag("transform", "How secret names are converted to environment variable names. "+
+		"upper: upper case, with characters other than letters, digits and _ replaced by _. "+
+		"none: names are used verbatim.").
+		Default(transformUpper).
+		Enum(transformUpper, transformNone)
+	params.parallelism = shared.ParallelismFlag(c)
+	params.command = c.Arg("command", "The command to run, followed by its arguments. Use -- to separate "+
+		"the command's flags from biscuit's.").Required().Strings()
+	return params
+}
+
+// Run the command.
+func (r *execCommand) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil {
+		return err
+	}
+	names, err := filterNames(entries.SecretNames(), *r.only, *r.exclude)
+	if err != nil {
+		return err
+	}
+	environment, err := r.environment(ctx, entries, names)
+	if err != nil {
+		return err
+	}
+
+	path, err := exec.LookPath((*r.command)[0])
+	if err != nil {
+		return err
+	}
+	return execProcess(path, *r.command, mergeEnviron(os.Environ(), environment))
+}
+
+// mergeEnviron returns parent with the variables in environment added, removing any variables of the same
+// names from parent. Processes read the first of duplicate variables, which would be the parent's.
+func mergeEnviron(parent, environment []string) []string {
+	envName := func(kv string) string {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if runtime.GOOS == "windows" {
+			return strings.ToUpper(name)
+		}
+		return name
+	}
+	replaced := make(map[string]bool)
+	for _, kv := range environment {
+		replaced[envName(kv)] = true
+	}
+	var merged []string
+	for _, kv := range parent {
+		if !replaced[envName(kv)] {
+			merged = append(merged, kv)
+		}
+	}
+	return append(merged, environment...)
+}
+
+// environment decrypts the named secrets and returns them as NAME=value pairs.
+func (r *execCommand) environment(ctx context.Context, entries store.EntryMap, names []string) ([]string, error) {
+	sources := make(map[string]string)
+	for _, name := range names {
+		envName := envVarName(*r.prefix+name, *r.transform)
+		if len(envName) == 0 || strings.ContainsAny(envName, "=\x00") {
+			return nil, fmt.Errorf("%s: %q is not a valid environment variable name; use --transform %s",
+				name, envName, transformUpper)
+		}
+		if other, present := sources[envName]; present {
+			return nil, &errDuplicateEnvName{envName, other, name}
+		}
+		sources[envName] = name
+	}
+
+	plaintexts, errs := decryptSecrets(ctx, *r.filename, entries, names, *r.regionPriority, *r.parallelism)
+	if err := firstFailure(names, errs); err != nil {
+		return nil, err
+	}
+	var environment []string
+	for envName, name := range sources {
+		if bytes.IndexByte(plaintexts[name], 0) >= 0 {
+			return nil, fmt.Errorf("%s: secrets containing NUL bytes cannot be stored in the environment", name)
+		}
+		environment = append(environment, envName+"="+string(plaintexts[name]))
+	}
+	sort.Strings(environment)
+	return environment, nil
+}
+
+// envVarName converts a secret name into an environment variable name.
+func envVarName(name, transform string) string {
+	if transform == transformNone {
+		return name
+	}
+	mapped := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+	if len(mapped) == 0 || (mapped[0] >= '0' && mapped[0] <= '9') {
+		mapped = "_" + mapped
+	}
+	return mapped
+}
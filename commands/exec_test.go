@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvVarName(t *testing.T) {
+	assert.Equal(t, "DB_PASSWORD", envVarName("db-password", transformUpper))
+	assert.Equal(t, "APP_API_KEY", envVarName("APP_api.key", transformUpper))
+	assert.Equal(t, "_1PASSWORD", envVarName("1password", transformUpper))
+	assert.Equal(t, "_", envVarName("", transformUpper))
+	assert.Equal(t, "db-password", envVarName("db-password", transformNone))
+}
+
+func TestMergeEnviron(t *testing.T) {
+	parent := []string{"HOME=/home/biscuit", "USERNAME=stale", "PATH=/bin"}
+	assert.Equal(t, []string{"HOME=/home/biscuit", "PATH=/bin", "USERNAME=oreilly"},
+		mergeEnviron(parent, []string{"USERNAME=oreilly"}))
+	assert.Equal(t, parent, mergeEnviron(parent, nil))
+}
+
+func TestEnvironment_invalidNames(t *testing.T) {
+	prefix, transform := "", transformNone
+	r := &execCommand{prefix: &prefix, transform: &transform}
+	for _, name := range []string{"a=b", "nul\x00", ""} {
+		_, err := r.environment(context.Background(), store.EntryMap{}, []string{name})
+		assert.Error(t, err, "%q", name)
+	}
+}
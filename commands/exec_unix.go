@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package commands
+
+import (
+	"syscall"
+)
+
+// execProcess replaces biscuit with the command so that signals and the exit status are delivered
+// directly to and from it.
+func execProcess(path string, args []string, env []string) error {
+	return syscall.Exec(path, args, env)
+}
@@ -0,0 +1,29 @@
+//go:build windows
+// +build windows
+
+package commands
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+)
+
+// execProcess runs the command and returns its exit status as an ExitStatus if it fails. Windows has no
+// equivalent of execve, so biscuit waits for the command and ignores interrupts, which the console also
+// delivers to the child.
+func execProcess(path string, args []string, env []string) error {
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	signal.Ignore(os.Interrupt)
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return ExitStatus(exitErr.ExitCode())
+		}
+		return err
+	}
+	return nil
+}
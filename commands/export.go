@@ -1,18 +1,49 @@
 package commands
 
 import (
-	"errors"
+	"bytes"
+	"context"
 	"fmt"
 	"os"
+	"runtime"
+	"sort"
+	"strings"
 
 	"github.com/primait/biscuit/shared"
 	"github.com/primait/biscuit/store"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
+// errExportFailures lists the secrets that could not be decrypted and were left out of an export.
+type errExportFailures struct {
+	failures map[string]error
+	total    int
+}
+
+func (e *errExportFailures) Error() string {
+	var names []string
+	for name := range e.failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := []string{fmt.Sprintf("%d of %d secrets could not be decrypted and were left out of the export:",
+		len(names), e.total)}
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("  %s: %s", name, e.failures[name]))
+	}
+	return strings.Join(lines, "\n")
+}
+
 type export struct {
 	filename       *string
 	regionPriority *[]string
+	format         *string
+	only           *[]string
+	exclude        *[]string
+	output         *string
+	kubernetesName *string
+	metadata       *bool
+	parallelism    *int
 }
 
 // NewExport configures the flags for export.
@@ -20,36 +51,113 @@ func NewExport(c *kingpin.CmdClause) shared.Command {
 	return &export{
 		filename:       shared.FilenameFlag(c),
 		regionPriority: shared.AwsRegionPriorityFlag(c),
+		format: c.Flag("format", "Output format. The dotenv and shell formats convert names to "+
+			"environment variable names as exec does. Options: "+strings.Join(exportFormats, ", ")).
+			Default(formatYaml).
+			Enum(exportFormats...),
+		only: c.Flag("only", "Export only the secrets whose names match PATTERN (ex: 'db-*'). "+
+			"May be repeated or separated by commas.").PlaceHolder("PATTERN").Strings(),
+		exclude: c.Flag("exclude", "Do not export secrets whose names match PATTERN. May be repeated or separated by "+
+			"commas.").
+			PlaceHolder("PATTERN").Strings(),
+		output: c.Flag("output", "Write to FILE instead of stdout. The file is only readable by its owner.").
+			PlaceHolder("FILE").
+			Short('o').
+			String(),
+		kubernetesName: c.Flag("kubernetes-name", "The name of the Secret when using the kubernetes format.").
+			Default(shared.ProgName).
+			String(),
+		metadata: c.Flag("metadata", "Include when and by whom each secret was last updated, its "+
+			"description and its tags. Only supported by the yaml and json formats.").Bool(),
+		parallelism: shared.ParallelismFlag(c),
 	}
 }
 
 // Run the command.
-func (r *export) Run() error {
-	database := store.NewFileStore(*r.filename)
+func (r *export) Run(ctx context.Context) error {
+	if *r.metadata && *r.format != formatYaml && *r.format != formatJSON {
+		return fmt.Errorf("--metadata is only supported by the %s and %s formats", formatYaml, formatJSON)
+	}
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
 	entries, err := database.GetAll()
 	if err != nil {
 		return err
 	}
-	errs := 0
-	for name, values := range entries {
-		if name == store.KeyTemplateName {
-			continue
+	names, err := filterNames(entries.SecretNames(), *r.only, *r.exclude)
+	if err != nil {
+		return err
+	}
+
+	plaintexts, failures := decryptSecrets(ctx, *r.filename, entries, names, *r.regionPriority, *r.parallelism)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	var secrets []plaintextSecret
+	for _, name := range names {
+		if _, failed := failures[name]; !failed {
+			secrets = append(secrets, plaintextSecret{name, plaintexts[name]})
 		}
+	}
 
-		store.SortByKmsRegion(*r.regionPriority)(values)
-		for _, v := range values {
-			bytes, err := decryptOneValue(v, name)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: unable to decrypt, skipping: %s\n", err)
-				errs++
-				continue
-			}
-			fmt.Print(shared.MustYaml(map[string]string{name: string(bytes)}))
-			break
+	var output bytes.Buffer
+	if *r.metadata {
+		metadata := make(map[string]*store.Metadata)
+		for _, secret := range secrets {
+			metadata[secret.name] = entries[secret.name].Metadata()
 		}
+		err = writeExportWithMetadata(&output, *r.format, secrets, metadata)
+	} else {
+		err = writeExport(&output, *r.format, secrets, *r.kubernetesName)
 	}
-	if errs > 0 {
-		return errors.New("there were errors exporting")
+	if err != nil {
+		return err
+	}
+	if len(*r.output) > 0 {
+		if err := writePrivateFile(*r.output, output.Bytes()); err != nil {
+			return err
+		}
+	} else {
+		warnIfStdoutShared()
+		if _, err := os.Stdout.Write(output.Bytes()); err != nil {
+			return err
+		}
+	}
+	if len(failures) > 0 {
+		return &errExportFailures{failures, len(names)}
 	}
 	return nil
 }
+
+// warnIfStdoutShared prints a warning if stdout was redirected to a file that users other than its owner can
+// read: biscuit does not change the permissions of a file the shell opened.
+func warnIfStdoutShared() {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	info, err := os.Stdout.Stat()
+	if err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0077 != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: stdout is a file that other users can read (%s); use --output to write "+
+			"a file that only you can read.\n", info.Mode().Perm())
+	}
+}
+
+// writePrivateFile writes contents to filename, ensuring that only the owner can read it.
+func writePrivateFile(filename string, contents []byte) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	// OpenFile does not change the permissions of an existing file, so do so before writing to it.
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(contents); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,18 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrExportFailures(t *testing.T) {
+	err := &errExportFailures{map[string]error{
+		"spice":    errors.New("access denied"),
+		"password": errors.New("illegal base64 data at input byte 0"),
+	}, 5}
+	assert.EqualError(t, err, "2 of 5 secrets could not be decrypted and were left out of the export:\n"+
+		"  password: illegal base64 data at input byte 0\n"+
+		"  spice: access denied")
+}
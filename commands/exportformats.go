@@ -0,0 +1,244 @@
+package commands
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/primait/biscuit/shared"
+	"github.com/primait/biscuit/store"
+)
+
+const (
+	formatYaml       = "yaml"
+	formatJSON       = "json"
+	formatDotenv     = "dotenv"
+	formatShell      = "shell"
+	formatProperties = "properties"
+	formatKubernetes = "kubernetes"
+)
+
+var (
+	exportFormats = []string{formatYaml, formatJSON, formatDotenv, formatShell, formatProperties,
+		formatKubernetes}
+
+	kubernetesKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+)
+
+// plaintextSecret is a decrypted secret.
+type plaintextSecret struct {
+	name      string
+	plaintext []byte
+}
+
+// secretWithMetadata is a secret exported along with its metadata.
+type secretWithMetadata struct {
+	Value          string `yaml:"value" json:"value"`
+	store.Metadata `yaml:",inline"`
+}
+
+// kubernetesSecret is a Kubernetes Secret manifest.
+type kubernetesSecret struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Type string            `yaml:"type"`
+	Data map[string]string `yaml:"data"`
+}
+
+// writeExport writes secrets to w in the requested format. kubernetesName is the name of the Secret in
+// the kubernetes format.
+func writeExport(w io.Writer, format string, secrets []plaintextSecret, kubernetesName string) error {
+	switch format {
+	case formatYaml:
+		_, err := io.WriteString(w, shared.MustYaml(secretMap(secrets)))
+		return err
+	case formatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(secretMap(secrets))
+	case formatDotenv, formatShell:
+		return writeEnvironment(w, format, secrets)
+	case formatProperties:
+		for _, secret := range secrets {
+			if _, err := fmt.Fprintf(w, "%s=%s\n", escapeProperty(secret.name, true),
+				escapeProperty(string(secret.plaintext), false)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatKubernetes:
+		manifest := kubernetesSecret{APIVersion: "v1", Kind: "Secret", Type: "Opaque",
+			Data: make(map[string]string)}
+		manifest.Metadata.Name = kubernetesName
+		for _, secret := range secrets {
+			if !kubernetesKeyPattern.MatchString(secret.name) {
+				return fmt.Errorf("%s: Kubernetes Secret keys may only contain letters, digits, '-', "+
+					"'_' and '.'", secret.name)
+			}
+			manifest.Data[secret.name] = base64.StdEncoding.EncodeToString(secret.plaintext)
+		}
+		_, err := io.WriteString(w, shared.MustYaml(manifest))
+		return err
+	}
+	return fmt.Errorf("unsupported format '%s'", format)
+}
+
+// writeExportWithMetadata writes secrets to w as a YAML or JSON map from each name to its plaintext and
+// metadata.
+func writeExportWithMetadata(w io.Writer, format string, secrets []plaintextSecret,
+	metadata map[string]*store.Metadata) error {
+	output := make(map[string]secretWithMetadata)
+	for _, secret := range secrets {
+		exported := secretWithMetadata{Value: string(secret.plaintext)}
+		if m := metadata[secret.name]; m != nil {
+			exported.Metadata = *m
+		}
+		output[secret.name] = exported
+	}
+	switch format {
+	case formatYaml:
+		_, err := io.WriteString(w, shared.MustYaml(output))
+		return err
+	case formatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(output)
+	}
+	return fmt.Errorf("metadata can only be exported in the %s and %s formats", formatYaml, formatJSON)
+}
+
+func secretMap(secrets []plaintextSecret) map[string]string {
+	output := make(map[string]string)
+	for _, secret := range secrets {
+		output[secret.name] = string(secret.plaintext)
+	}
+	return output
+}
+
+// writeEnvironment writes secrets as NAME="value" (dotenv) or export NAME='value' (shell) lines. Names
+// are converted to environment variable names as by exec.
+func writeEnvironment(w io.Writer, format string, secrets []plaintextSecret) error {
+	sources := make(map[string]string)
+	for _, secret := range secrets {
+		envName := envVarName(secret.name, transformUpper)
+		if other, present := sources[envName]; present {
+			return &errDuplicateEnvName{envName, other, secret.name}
+		}
+		sources[envName] = secret.name
+	}
+	for _, secret := range secrets {
+		envName := envVarName(secret.name, transformUpper)
+		var err error
+		if format == formatShell {
+			_, err = fmt.Fprintf(w, "export %s=%s\n", envName, quoteShell(string(secret.plaintext)))
+		} else {
+			_, err = fmt.Fprintf(w, "%s=%s\n", envName, quoteDotenv(string(secret.plaintext)))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quoteShell quotes s for a POSIX shell. Nothing is special within single quotes except the single quote
+// itself.
+func quoteShell(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// quoteDotenv quotes s in the double-quoted form understood by most dotenv parsers.
+func quoteDotenv(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`)
+	return `"` + replacer.Replace(s) + `"`
+}
+
+// escapeProperty escapes s for a Java .properties file. Keys additionally escape separators and spaces.
+func escapeProperty(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == ' ' && (isKey || i == 0):
+			b.WriteString(`\ `)
+		case isKey && strings.ContainsRune("=:#!", r), !isKey && i == 0 && strings.ContainsRune("#!", r):
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r > 0xffff:
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(&b, `\u%04x\u%04x`, r1, r2)
+		case r < 0x20 || r > 0x7e:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// filterNames returns the names matching any of the only patterns (or all names, if there are none) and
+// none of the exclude patterns. Patterns use path.Match syntax, and a value may hold several patterns
+// separated by commas. It is an error for an only pattern to match no name, as it is probably a typo.
+func filterNames(names, only, exclude []string) ([]string, error) {
+	only, exclude = splitPatterns(only), splitPatterns(exclude)
+	for _, pattern := range append(append([]string{}, only...), exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: %s", pattern, err)
+		}
+	}
+	matchesAny := func(name string, patterns []string) bool {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+		}
+		return false
+	}
+	for _, pattern := range only {
+		matched := false
+		for _, name := range names {
+			matched = matched || matchesAny(name, []string{pattern})
+		}
+		if !matched {
+			return nil, fmt.Errorf("--only %s: no secret matches", pattern)
+		}
+	}
+	var filtered []string
+	for _, name := range names {
+		if (len(only) == 0 || matchesAny(name, only)) && !matchesAny(name, exclude) {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered, nil
+}
+
+// splitPatterns splits each of the values of a repeatable pattern flag on commas, so that --only a,b is the
+// same as --only a --only b.
+func splitPatterns(values []string) []string {
+	var patterns []string
+	for _, value := range values {
+		for _, pattern := range strings.Split(value, ",") {
+			if pattern = strings.TrimSpace(pattern); len(pattern) > 0 {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+	return patterns
+}
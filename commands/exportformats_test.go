@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+var exportTestSecrets = []plaintextSecret{
+	{"api.key", []byte("it's \"quoted\" $HOME")},
+	{"db-password", []byte("line one\nline two")},
+}
+
+func exportString(t *testing.T, format string) string {
+	var output bytes.Buffer
+	assert.NoError(t, writeExport(&output, format, exportTestSecrets, "app-secrets"))
+	return output.String()
+}
+
+func TestWriteExport(t *testing.T) {
+	assert.Equal(t, "api.key: it's \"quoted\" $HOME\ndb-password: |-\n  line one\n  line two\n",
+		exportString(t, formatYaml))
+	assert.Equal(t, "{\n  \"api.key\": \"it's \\\"quoted\\\" $HOME\",\n"+
+		"  \"db-password\": \"line one\\nline two\"\n}\n", exportString(t, formatJSON))
+	assert.Equal(t, "API_KEY=\"it's \\\"quoted\\\" \\$HOME\"\nDB_PASSWORD=\"line one\\nline two\"\n",
+		exportString(t, formatDotenv))
+	assert.Equal(t, "export API_KEY='it'\\''s \"quoted\" $HOME'\nexport DB_PASSWORD='line one\nline two'\n",
+		exportString(t, formatShell))
+	assert.Equal(t, "api.key=it's \"quoted\" $HOME\ndb-password=line one\\nline two\n",
+		exportString(t, formatProperties))
+	assert.Equal(t, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: app-secrets\ntype: Opaque\ndata:\n"+
+		"  api.key: aXQncyAicXVvdGVkIiAkSE9NRQ==\n  db-password: bGluZSBvbmUKbGluZSB0d28=\n",
+		exportString(t, formatKubernetes))
+}
+
+func TestWriteExport_Errors(t *testing.T) {
+	var output bytes.Buffer
+	assert.Error(t, writeExport(&output, formatDotenv,
+		[]plaintextSecret{{"db-password", nil}, {"DB_PASSWORD", nil}}, ""))
+	assert.Error(t, writeExport(&output, formatKubernetes, []plaintextSecret{{"db password", nil}}, ""))
+}
+
+func TestEscapeProperty(t *testing.T) {
+	assert.Equal(t, `a\ key\=\:\#\!`, escapeProperty("a key=:#!", true))
+	assert.Equal(t, `\ leading`, escapeProperty(" leading", false))
+	assert.Equal(t, `\#comment a=b:c#d`, escapeProperty("#comment a=b:c#d", false))
+	assert.Equal(t, `caf\u00e9 \ud83c\udf6a \\ \t`, escapeProperty("café 🍪 \\ \t", false))
+}
+
+func TestFilterNames(t *testing.T) {
+	names := []string{"api-key", "db-password", "db-username"}
+	filtered, err := filterNames(names, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, names, filtered)
+
+	filtered, err = filterNames(names, []string{"db-*"}, []string{"*-username"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db-password"}, filtered)
+
+	filtered, err = filterNames(names, []string{"api-key,db-username", "api-*"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"api-key", "db-username"}, filtered)
+
+	_, err = filterNames(names, []string{"["}, nil)
+	assert.Error(t, err)
+	_, err = filterNames(names, []string{"db-*,zzz"}, nil)
+	assert.EqualError(t, err, "--only zzz: no secret matches")
+}
+
+func TestWriteExportWithMetadata(t *testing.T) {
+	metadata := map[string]*store.Metadata{
+		"api.key": {
+			UpdatedAt:   time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC),
+			UpdatedBy:   "oreilly",
+			Description: "launch codes",
+		},
+	}
+	var output bytes.Buffer
+	assert.NoError(t, writeExportWithMetadata(&output, formatYaml, exportTestSecrets, metadata))
+	assert.Equal(t, "api.key:\n  value: it's \"quoted\" $HOME\n  updated_at: 2021-09-01T12:00:00Z\n"+
+		"  updated_by: oreilly\n  description: launch codes\ndb-password:\n  value: |-\n    line one\n"+
+		"    line two\n", output.String())
+
+	output.Reset()
+	assert.NoError(t, writeExportWithMetadata(&output, formatJSON, exportTestSecrets[:1], metadata))
+	assert.Equal(t, "{\n  \"api.key\": {\n    \"value\": \"it's \\\"quoted\\\" $HOME\",\n"+
+		"    \"updated_at\": \"2021-09-01T12:00:00Z\",\n    \"updated_by\": \"oreilly\",\n"+
+		"    \"description\": \"launch codes\"\n  }\n}\n", output.String())
+
+	assert.Error(t, writeExportWithMetadata(&output, formatDotenv, exportTestSecrets, metadata))
+}
@@ -0,0 +1,409 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/primait/biscuit/store"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+const (
+	contentTypeJSON = "json"
+	contentTypeYaml = "yaml"
+	contentTypeText = "text"
+)
+
+var (
+	contentTypes = []string{contentTypeJSON, contentTypeYaml, contentTypeText}
+
+	errNotStructured = errors.New("the secret is not a JSON or YAML document")
+)
+
+// A structured secret is parsed into a tree that keeps the text of every scalar and the order of every key,
+// so that setting one field leaves the rest of the document as it was written. JSON documents are made of
+// *jsonObject, []interface{} and json.RawMessage (scalars, as written); YAML documents are yaml.v3 nodes.
+
+// jsonObject is a JSON object that remembers the order of its keys.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: make(map[string]interface{})}
+}
+
+func (o *jsonObject) set(key string, value interface{}) {
+	if _, present := o.values[key]; !present {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// MarshalJSON encodes the object with its keys in their original order.
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	var output bytes.Buffer
+	output.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			output.WriteByte(',')
+		}
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		output.Write(encodedKey)
+		output.WriteByte(':')
+		output.Write(value)
+	}
+	output.WriteByte('}')
+	return output.Bytes(), nil
+}
+
+// parseStructured decodes a JSON or YAML document. contentType is the content_type hint of the secret; if it
+// is empty, JSON is tried before YAML. It returns the document and the content type it was decoded as.
+func parseStructured(plaintext []byte, contentType string) (interface{}, string, error) {
+	if contentType == contentTypeText {
+		return nil, "", errNotStructured
+	}
+	if contentType != contentTypeYaml {
+		document, err := parseJSON(plaintext)
+		if err == nil && isContainer(document) {
+			return document, contentTypeJSON, nil
+		}
+		if contentType == contentTypeJSON {
+			if err == nil {
+				err = errNotStructured
+			}
+			return nil, "", err
+		}
+	}
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(plaintext, &document); err != nil {
+		return nil, "", err
+	}
+	if !isContainer(&document) {
+		return nil, "", errNotStructured
+	}
+	return &document, contentTypeYaml, nil
+}
+
+// parseJSON decodes the first JSON value of data into a tree of *jsonObject, []interface{} and
+// json.RawMessage.
+func parseJSON(data []byte) (interface{}, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&raw); err != nil {
+		return nil, err
+	}
+	return jsonValue(raw)
+}
+
+func jsonValue(raw json.RawMessage) (interface{}, error) {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case bytes.HasPrefix(raw, []byte("{")):
+		object := newJSONObject()
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			var child json.RawMessage
+			if err := decoder.Decode(&child); err != nil {
+				return nil, err
+			}
+			value, err := jsonValue(child)
+			if err != nil {
+				return nil, err
+			}
+			object.set(key.(string), value)
+		}
+		return object, nil
+	case bytes.HasPrefix(raw, []byte("[")):
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+		array := make([]interface{}, len(items))
+		for i, item := range items {
+			value, err := jsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			array[i] = value
+		}
+		return array, nil
+	}
+	return raw, nil
+}
+
+// newStructured returns an empty object of contentType, for a secret that does not exist yet.
+func newStructured(contentType string) interface{} {
+	if contentType == contentTypeYaml {
+		return &yamlv3.Node{Kind: yamlv3.DocumentNode, Content: []*yamlv3.Node{newYamlMapping()}}
+	}
+	return newJSONObject()
+}
+
+// marshalStructured encodes a document parsed by parseStructured. JSON documents are indented if original,
+// the document before it was modified, spanned multiple lines.
+func marshalStructured(document interface{}, contentType string, original []byte) ([]byte, error) {
+	if contentType == contentTypeYaml {
+		return encodeYaml(document.(*yamlv3.Node))
+	}
+	if bytes.Contains(bytes.TrimSpace(original), []byte("\n")) {
+		return json.MarshalIndent(document, "", "  ")
+	}
+	return json.Marshal(document)
+}
+
+func encodeYaml(node *yamlv3.Node) ([]byte, error) {
+	var output bytes.Buffer
+	encoder := yamlv3.NewEncoder(&output)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+// formatField returns the plaintext of a field: scalars as they were written (strings unquoted), and objects
+// and arrays encoded as contentType.
+func formatField(field interface{}, contentType string) ([]byte, error) {
+	switch v := field.(type) {
+	case json.RawMessage:
+		if bytes.Equal(v, []byte("null")) {
+			return []byte{}, nil
+		}
+		if bytes.HasPrefix(v, []byte(`"`)) {
+			var s string
+			err := json.Unmarshal(v, &s)
+			return []byte(s), err
+		}
+		return v, nil
+	case *yamlv3.Node:
+		if v.Kind == yamlv3.ScalarNode {
+			if v.ShortTag() == "!!null" {
+				return []byte{}, nil
+			}
+			return []byte(v.Value), nil
+		}
+		encoded, err := encodeYaml(v)
+		return bytes.TrimSuffix(encoded, []byte("\n")), err
+	}
+	return json.Marshal(field)
+}
+
+// extractField returns the plaintext of the field at path in a structured secret.
+func extractField(plaintext []byte, metadata *store.Metadata, path string) ([]byte, error) {
+	var contentType string
+	if metadata != nil {
+		contentType = metadata.ContentType
+	}
+	document, contentType, err := parseStructured(plaintext, contentType)
+	if err != nil {
+		return nil, err
+	}
+	field, err := getField(document, path)
+	if err != nil {
+		return nil, err
+	}
+	return formatField(field, contentType)
+}
+
+// getField returns the field at path, a list of map keys and array indexes separated by dots, ex:
+// "database.replicas.0.host".
+func getField(document interface{}, path string) (interface{}, error) {
+	parts := splitFieldPath(path)
+	current := document
+	for i, part := range parts {
+		var field interface{}
+		present := false
+		switch v := current.(type) {
+		case *jsonObject:
+			field, present = v.values[part]
+		case []interface{}:
+			if index, err := strconv.Atoi(part); err == nil && index >= 0 && index < len(v) {
+				field, present = v[index], true
+			}
+		case *yamlv3.Node:
+			v = yamlTarget(v)
+			if v.Kind != yamlv3.MappingNode && v.Kind != yamlv3.SequenceNode {
+				return nil, fmt.Errorf("%s: not an object or array", strings.Join(parts[:i], "."))
+			}
+			if child := yamlChild(v, part); child != nil {
+				field, present = child, true
+			}
+		default:
+			return nil, fmt.Errorf("%s: not an object or array", strings.Join(parts[:i], "."))
+		}
+		if !present {
+			return nil, fmt.Errorf("%s: field not found", strings.Join(parts[:i+1], "."))
+		}
+		current = field
+	}
+	if node, ok := current.(*yamlv3.Node); ok {
+		return yamlTarget(node), nil
+	}
+	return current, nil
+}
+
+// setField sets the field at path to the string value, creating objects for any missing or null parents,
+// and returns the modified document. Every other field is left as it was.
+func setField(document interface{}, path string, value string) (interface{}, error) {
+	parts := splitFieldPath(path)
+	if node, ok := document.(*yamlv3.Node); ok {
+		return node, setYamlField(node, parts, value)
+	}
+	return setJSONField(document, parts, value)
+}
+
+func setJSONField(document interface{}, parts []string, value string) (interface{}, error) {
+	if len(parts) == 0 {
+		encoded, err := json.Marshal(value)
+		return json.RawMessage(encoded), err
+	}
+	if raw, ok := document.(json.RawMessage); document == nil || ok && bytes.Equal(raw, []byte("null")) {
+		document = newJSONObject()
+	}
+	switch v := document.(type) {
+	case *jsonObject:
+		child, err := setJSONField(v.values[parts[0]], parts[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		v.set(parts[0], child)
+		return v, nil
+	case []interface{}:
+		index, err := strconv.Atoi(parts[0])
+		if err != nil || index < 0 || index >= len(v) {
+			return nil, fmt.Errorf("%s: index out of range", parts[0])
+		}
+		child, err := setJSONField(v[index], parts[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		v[index] = child
+		return v, nil
+	}
+	return nil, fmt.Errorf("%s: cannot set a field inside %s", parts[0], document)
+}
+
+// setYamlField sets the field at parts below node, a document, mapping or sequence.
+func setYamlField(node *yamlv3.Node, parts []string, value string) error {
+	if node.Kind == yamlv3.DocumentNode {
+		node = node.Content[0]
+	}
+	node = yamlTarget(node)
+	for i, part := range parts {
+		if node.Kind == yamlv3.ScalarNode && node.ShortTag() == "!!null" {
+			*node = *newYamlMapping()
+		}
+		var slot **yamlv3.Node
+		switch node.Kind {
+		case yamlv3.MappingNode:
+			for j := len(node.Content) - 2; j >= 0; j -= 2 {
+				if node.Content[j].Value == part {
+					slot = &node.Content[j+1]
+					break
+				}
+			}
+			if slot == nil {
+				key := &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: part}
+				node.Content = append(node.Content, key, &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!null"})
+				slot = &node.Content[len(node.Content)-1]
+			}
+		case yamlv3.SequenceNode:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(node.Content) {
+				return fmt.Errorf("%s: index out of range", part)
+			}
+			slot = &node.Content[index]
+		default:
+			return fmt.Errorf("%s: cannot set a field inside a %s", part, node.ShortTag())
+		}
+		if i == len(parts)-1 {
+			previous := *slot
+			*slot = &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: value,
+				HeadComment: previous.HeadComment, LineComment: previous.LineComment, FootComment: previous.FootComment}
+			return nil
+		}
+		if (*slot).Kind == yamlv3.AliasNode {
+			// Copy the aliased node so that the change does not affect every alias of it.
+			copied := *yamlTarget(*slot)
+			copied.Anchor = ""
+			*slot = &copied
+		}
+		node = *slot
+	}
+	return nil
+}
+
+// yamlChild returns the value of the key part of a mapping, or the element at index part of a sequence.
+func yamlChild(node *yamlv3.Node, part string) *yamlv3.Node {
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		for i := len(node.Content) - 2; i >= 0; i -= 2 {
+			if node.Content[i].Value == part {
+				return node.Content[i+1]
+			}
+		}
+	case yamlv3.SequenceNode:
+		if index, err := strconv.Atoi(part); err == nil && index >= 0 && index < len(node.Content) {
+			return node.Content[index]
+		}
+	}
+	return nil
+}
+
+// yamlTarget returns the node that a document or alias node stands for.
+func yamlTarget(node *yamlv3.Node) *yamlv3.Node {
+	for {
+		switch {
+		case node.Kind == yamlv3.DocumentNode && len(node.Content) > 0:
+			node = node.Content[0]
+		case node.Kind == yamlv3.AliasNode && node.Alias != nil:
+			node = node.Alias
+		default:
+			return node
+		}
+	}
+}
+
+func newYamlMapping() *yamlv3.Node {
+	return &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map"}
+}
+
+func splitFieldPath(path string) []string {
+	if len(path) == 0 {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+func isContainer(document interface{}) bool {
+	switch v := document.(type) {
+	case *jsonObject, []interface{}:
+		return true
+	case *yamlv3.Node:
+		kind := yamlTarget(v).Kind
+		return kind == yamlv3.MappingNode || kind == yamlv3.SequenceNode
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractField(t *testing.T) {
+	jsonSecret := []byte(`{"database": {"replicas": [{"host": "db1", "port": 5432}], "password": "god"}}`)
+	yamlSecret := []byte("database:\n  replicas:\n  - host: db1\n    port: 5432\n  password: god\n")
+	for _, plaintext := range [][]byte{jsonSecret, yamlSecret} {
+		field, err := extractField(plaintext, nil, "database.password")
+		require.NoError(t, err)
+		assert.Equal(t, "god", string(field))
+		field, err = extractField(plaintext, nil, "database.replicas.0.port")
+		require.NoError(t, err)
+		assert.Equal(t, "5432", string(field))
+		_, err = extractField(plaintext, nil, "database.replicas.1")
+		assert.EqualError(t, err, "database.replicas.1: field not found")
+		_, err = extractField(plaintext, nil, "database.password.length")
+		assert.EqualError(t, err, "database.password: not an object or array")
+	}
+
+	field, err := extractField(jsonSecret, nil, "database.replicas")
+	require.NoError(t, err)
+	assert.Equal(t, `[{"host":"db1","port":5432}]`, string(field))
+	field, err = extractField(yamlSecret, &store.Metadata{ContentType: contentTypeYaml}, "database.replicas")
+	require.NoError(t, err)
+	assert.Equal(t, "- host: db1\n  port: 5432", string(field))
+
+	_, err = extractField([]byte("hunter2"), nil, "password")
+	assert.Equal(t, errNotStructured, err)
+	_, err = extractField(jsonSecret, &store.Metadata{ContentType: contentTypeText}, "database")
+	assert.Equal(t, errNotStructured, err)
+}
+
+func TestSetField(t *testing.T) {
+	original := []byte(`{"a": {"b": [1, {"c": "x"}]}, "n": 12345678901234567890}`)
+	document, contentType, err := parseStructured(original, "")
+	require.NoError(t, err)
+	assert.Equal(t, contentTypeJSON, contentType)
+
+	document, err = setField(document, "a.b.1.c", "y")
+	require.NoError(t, err)
+	document, err = setField(document, "d.e", "z")
+	require.NoError(t, err)
+	_, err = setField(document, "a.b.2", "z")
+	assert.Error(t, err)
+	_, err = setField(document, "d.e.f", "z")
+	assert.Error(t, err)
+
+	plaintext, err := marshalStructured(document, contentType, original)
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":{"b":[1,{"c":"y"}]},"n":12345678901234567890,"d":{"e":"z"}}`, string(plaintext))
+	plaintext, err = marshalStructured(map[string]interface{}{"a": "b"}, contentTypeJSON, []byte("{\n}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"a\": \"b\"\n}", string(plaintext))
+}
+
+func TestSetField_keepsOtherFields(t *testing.T) {
+	for _, c := range []struct {
+		original, expected, contentType string
+	}{
+		{
+			"db:\n  pin: 0755\n  version: 1.10\n  ssl: on\n  password: god\nzone: 0x1F # hex\n",
+			"db:\n  pin: 0755\n  version: 1.10\n  ssl: on\n  password: \"0644\"\nzone: 0x1F # hex\n",
+			contentTypeYaml,
+		},
+		{
+			`{"z": 1.10, "db": {"pin": 10.50, "ssl": true, "password": "god"}, "a": 1e3}`,
+			`{"z":1.10,"db":{"pin":10.50,"ssl":true,"password":"0644"},"a":1e3}`,
+			contentTypeJSON,
+		},
+	} {
+		document, contentType, err := parseStructured([]byte(c.original), "")
+		if !assert.NoError(t, err, c.original) {
+			continue
+		}
+		assert.Equal(t, c.contentType, contentType)
+		document, err = setField(document, "db.password", "0644")
+		require.NoError(t, err)
+		plaintext, err := marshalStructured(document, contentType, []byte(c.original))
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, string(plaintext))
+
+		field, err := extractField(plaintext, nil, "db.password")
+		require.NoError(t, err)
+		assert.Equal(t, "0644", string(field))
+	}
+
+	field, err := extractField([]byte("db:\n  pin: 0755\n  version: 1.10\n  ssl: on\n"), nil, "db.pin")
+	require.NoError(t, err)
+	assert.Equal(t, "0755", string(field))
+	field, err = extractField([]byte(`{"version": 1.10}`), nil, "version")
+	require.NoError(t, err)
+	assert.Equal(t, "1.10", string(field))
+}
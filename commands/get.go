@@ -1,12 +1,13 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"time"
 
-	"github.com/primait/biscuit/algorithms"
-	"github.com/primait/biscuit/keymanager"
+	"github.com/primait/biscuit/internal/envelope"
 	"github.com/primait/biscuit/shared"
 	"github.com/primait/biscuit/store"
 	"github.com/mattn/go-isatty"
@@ -18,6 +19,9 @@ type get struct {
 	writeTo        *string
 	filename       *string
 	regionPriority *[]string
+	field          *string
+	hedgeAfter     *time.Duration
+	verbose        *bool
 }
 
 // NewGet constructs the command to decrypt an encrypted value.
@@ -30,34 +34,50 @@ func NewGet(c *kingpin.CmdClause) shared.Command {
 			Short('o').
 			String(),
 		filename: shared.FilenameFlag(c),
+		field: c.Flag("field", "Print only the field at PATH of a JSON or YAML secret, ex: "+
+			"database.replicas.0.host.").PlaceHolder("PATH").String(),
+		hedgeAfter: c.Flag("hedge-after", "If decryption under a value has not finished after this long, "+
+			"also try the next value and use whichever answers first, ex: 200ms. By default, values are "+
+			"tried one at a time.").PlaceHolder("DURATION").Duration(),
+		verbose: c.Flag("verbose", "Report which value the secret was decrypted under.").Short('v').Bool(),
 	}
 }
 
 // Run the command.
-func (r *get) Run() error {
-	database := store.NewFileStore(*r.filename)
+func (r *get) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
 	values, err := database.Get(*r.name)
 	if err != nil {
 		return err
 	}
 	store.SortByKmsRegion(*r.regionPriority)(values)
-	// There may be multiple values, but we assume that each one represents the same contents
-	// so we stop after processing just one successfully.
 	var plaintext []byte
-	for _, value := range values {
-		plaintext, err = decryptOneValue(value, *r.name)
-		if err != nil {
-			fmt.Fprintf(os.Stderr,
-				"Warning: decryption under %s failed: %s\n",
-				value.KeyManager,
-				err)
-			continue
+	started := time.Now()
+	if plaintexts, errs, ok := agentDecrypt(ctx, *r.filename, []string{*r.name}, *r.regionPriority); ok {
+		plaintext, err = plaintexts[*r.name], errs[*r.name]
+		if err == nil && *r.verbose {
+			fmt.Fprintf(os.Stderr, "Decrypted %s through the agent in %s.\n", *r.name,
+				time.Since(started).Round(time.Millisecond))
+		}
+	} else {
+		var value store.Value
+		plaintext, value, err = decryptHedged(ctx, values, *r.name, *r.hedgeAfter)
+		if err == nil && *r.verbose && len(values) > 0 {
+			fmt.Fprintf(os.Stderr, "Decrypted %s under %s in %s.\n", *r.name, keyLocation(value.Key),
+				time.Since(started).Round(time.Millisecond))
 		}
-		break
 	}
 	if err != nil {
 		return err
 	}
+	if len(*r.field) > 0 {
+		if plaintext, err = extractField(plaintext, values.Metadata(), *r.field); err != nil {
+			return fmt.Errorf("%s: %s", *r.name, err)
+		}
+	}
 
 	if len(*r.writeTo) > 0 {
 		return ioutil.WriteFile(*r.writeTo, plaintext, 0644)
@@ -70,38 +90,92 @@ func (r *get) Run() error {
 	return nil
 }
 
-func decryptOneValue(value store.Value, name string) ([]byte, error) {
-	algo, err := algorithms.New(value.Algorithm)
-	if err != nil {
-		return []byte{}, err
-	}
-	var keyPlaintext []byte
-	if algo.NeedsKey() {
-		keyPlaintext, err = getPlaintextKeyFromManager(value, name)
-		if err != nil {
-			return nil, err
-		}
-	}
-	decoded, err := value.GetCiphertext()
-	if err != nil {
-		return []byte{}, err
-	}
-	plaintext, err := algo.Decrypt(keyPlaintext, decoded)
+// decryptAnyValue returns the plaintext of the first value that can be decrypted. There may be multiple
+// values, but we assume that each one represents the same contents so we stop after processing just one
+// successfully.
+func decryptAnyValue(ctx context.Context, values store.ValueList, name string) ([]byte, error) {
+	plaintext, _, err := decryptHedged(ctx, values, name, 0)
 	return plaintext, err
 }
 
-func getPlaintextKeyFromManager(value store.Value, name string) ([]byte, error) {
-	keyManager, err := keymanager.New(value.KeyManager)
-	if err != nil {
-		return []byte{}, err
+type hedgedResult struct {
+	index     int
+	plaintext []byte
+	err       error
+}
+
+// decryptHedged returns the plaintext of the first value that can be decrypted, and that value. Values are
+// tried in order, each after the previous one fails. If hedgeAfter is positive, the next value is also tried
+// whenever none of the attempts in flight has answered within hedgeAfter; the first success wins and the
+// other attempts are canceled.
+func decryptHedged(ctx context.Context, values store.ValueList, name string, hedgeAfter time.Duration) ([]byte,
+	store.Value, error) {
+	if len(values) == 0 {
+		return nil, store.Value{}, nil
 	}
-	keyCiphertext, err := value.GetKeyCiphertext()
-	if err != nil {
-		return []byte{}, err
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan hedgedResult, len(values))
+	next, running := 0, 0
+	startNext := func() {
+		go func(index int) {
+			plaintext, err := decryptOneValue(attemptCtx, values[index], name)
+			results <- hedgedResult{index, plaintext, err}
+		}(next)
+		next++
+		running++
 	}
-	keyPlaintext, err := keyManager.Decrypt(value.Key.KeyID, keyCiphertext, name)
-	if err != nil {
-		return []byte{}, err
+
+	var hedge <-chan time.Time
+	var timer *time.Timer
+	if hedgeAfter > 0 {
+		timer = time.NewTimer(hedgeAfter)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+	resetHedge := func() {
+		if timer != nil && next < len(values) {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(hedgeAfter)
+		}
 	}
-	return keyPlaintext, nil
+
+	startNext()
+	var err error
+	for running > 0 {
+		select {
+		case result := <-results:
+			running--
+			if result.err == nil {
+				return result.plaintext, values[result.index], nil
+			}
+			if ctx.Err() != nil {
+				return nil, store.Value{}, ctx.Err()
+			}
+			fmt.Fprintf(os.Stderr,
+				"Warning: decryption under %s failed: %s\n",
+				values[result.index].KeyManager,
+				result.err)
+			err = result.err
+			if next < len(values) {
+				startNext()
+				resetHedge()
+			}
+		case <-hedge:
+			if next < len(values) {
+				startNext()
+				resetHedge()
+			}
+		}
+	}
+	return nil, store.Value{}, err
+}
+
+func decryptOneValue(ctx context.Context, value store.Value, name string) ([]byte, error) {
+	return envelope.Decrypt(ctx, value, name, envelope.DataKeys(keyManagers(ctx)))
 }
@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecryptHedged(t *testing.T) {
+	ctx := context.Background()
+	god, err := encryptOne(ctx, store.Key{Algorithm: "none"}, "password", []byte("god"))
+	require.NoError(t, err)
+	corrupt := god
+	corrupt.Ciphertext = "!"
+
+	for _, hedgeAfter := range []time.Duration{0, time.Millisecond} {
+		plaintext, value, err := decryptHedged(ctx, store.ValueList{corrupt, god}, "password", hedgeAfter)
+		require.NoError(t, err)
+		assert.Equal(t, "god", string(plaintext))
+		assert.Equal(t, god, value)
+
+		_, _, err = decryptHedged(ctx, store.ValueList{corrupt, corrupt}, "password", hedgeAfter)
+		assert.Error(t, err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, _, err = decryptHedged(canceled, store.ValueList{corrupt, god}, "password", 0)
+	assert.Equal(t, context.Canceled, err)
+}
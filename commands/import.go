@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/primait/biscuit/algorithms"
+	"github.com/primait/biscuit/internal/envelope"
+	"github.com/primait/biscuit/internal/keycache"
+	"github.com/primait/biscuit/shared"
+	"github.com/primait/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	errConflictingPolicies = errors.New("Please specify either --overwrite or --skip-existing, but not both.")
+	errImportNeedsTemplate = errors.New("The file you've specified does not exist. Please create a file with " +
+		"kms init or put a secret with --key-id first.")
+)
+
+type errSecretsExist struct {
+	names []string
+}
+
+func (e *errSecretsExist) Error() string {
+	return fmt.Sprintf("These secrets already exist: %s. Use --overwrite to replace them or "+
+		"--skip-existing to keep them.", strings.Join(e.names, ", "))
+}
+
+type importSecrets struct {
+	source, format, filename, algo *string
+	overwrite, skipExisting        *bool
+	parallelism, reuseDataKey      *int
+}
+
+// NewImport configures the command to encrypt secrets from a plaintext document.
+func NewImport(c *kingpin.CmdClause) shared.Command {
+	return &importSecrets{
+		source: c.Arg("source", "Plaintext document to import, or - for stdin.").Required().String(),
+		format: c.Flag("format", "Format of the document. auto detects the format from the file extension "+
+			"or contents. Options: "+strings.Join(importFormats, ", ")).
+			Default(formatAuto).
+			Enum(importFormats...),
+		algo: c.Flag("algorithm", "Encrypt using this algorithm instead of the one in the "+
+			store.KeyTemplateName+" entry. Options: "+strings.Join(algorithms.GetAlgorithms(), ", ")).
+			Short('a').
+			Enum(algorithms.GetAlgorithms()...),
+		overwrite:    c.Flag("overwrite", "Replace secrets that already exist.").Bool(),
+		skipExisting: c.Flag("skip-existing", "Leave secrets that already exist unchanged.").Bool(),
+		reuseDataKey: c.Flag("reuse-data-key", "Encrypt up to N secrets under each AWS KMS data key, deriving a "+
+			"different key for each secret from it, so that importing and exporting many secrets makes fewer "+
+			"calls to KMS. Fails for keys whose policy or grants refer to the SecretName encryption context.").
+			PlaceHolder("N").
+			Int(),
+		parallelism: shared.ParallelismFlag(c),
+		filename:    shared.FilenameFlag(c),
+	}
+}
+
+// Run the command.
+func (r *importSecrets) Run(ctx context.Context) error {
+	if *r.overwrite && *r.skipExisting {
+		return errConflictingPolicies
+	}
+	options := keyManagerOptions(ctx)
+	if *r.reuseDataKey > 1 {
+		options.Batch = keycache.New(0, *r.reuseDataKey)
+		defer options.Batch.Close()
+	}
+	secrets, err := r.readSource()
+	if err != nil {
+		return err
+	}
+
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	keys, err := database.GetKeyIds()
+	if os.IsNotExist(err) {
+		return errImportNeedsTemplate
+	}
+	if err != nil {
+		return err
+	}
+	if len(*r.algo) > 0 {
+		for i := range keys {
+			keys[i].Algorithm = *r.algo
+		}
+	}
+	entries, version, err := database.GetAllVersion()
+	if err != nil {
+		return err
+	}
+
+	var names, existing []string
+	for name := range secrets {
+		if _, present := entries[name]; present {
+			existing = append(existing, name)
+			if *r.skipExisting {
+				continue
+			}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sort.Strings(existing)
+	if len(existing) > 0 && !*r.overwrite && !*r.skipExisting {
+		return &errSecretsExist{existing}
+	}
+
+	author := currentAuthor(ctx, keys)
+	var mu sync.Mutex
+	encrypted := make(store.EntryMap)
+	var errs []error
+	forEachParallel(names, *r.parallelism, func(name string) {
+		valueList, err := envelope.EncryptAll(ctx, options.New, keys, name, secrets[name])
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", name, err))
+			return
+		}
+		encrypted[name] = setMetadata(valueList, touchMetadata(entries[name].Metadata(), author))
+	})
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+		return fmt.Errorf("%d of %d secrets could not be encrypted; nothing was imported", len(errs),
+			len(names))
+	}
+
+	if len(encrypted) > 0 {
+		if err := database.PutAllIfVersion(version, encrypted); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		fmt.Printf("%s: imported (%s)\n", name, describeValues(encrypted[name]))
+	}
+	if *r.skipExisting {
+		for _, name := range existing {
+			fmt.Printf("%s: skipped (already exists)\n", name)
+		}
+	}
+	return nil
+}
+
+func (r *importSecrets) readSource() (map[string][]byte, error) {
+	var data []byte
+	var err error
+	if *r.source == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(*r.source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	format := *r.format
+	if format == formatAuto {
+		format = detectImportFormat(*r.source, data)
+	}
+	secrets, err := parseImport(format, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", *r.source, err)
+	}
+	if _, present := secrets[store.KeyTemplateName]; present {
+		return nil, fmt.Errorf("%s: the %s entry cannot be imported", *r.source, store.KeyTemplateName)
+	}
+	return secrets, nil
+}
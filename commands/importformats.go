@@ -0,0 +1,300 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+const formatAuto = "auto"
+
+var importFormats = []string{formatAuto, formatYaml, formatJSON, formatDotenv}
+
+// detectImportFormat guesses the format of a document from its filename or, failing that, its contents.
+func detectImportFormat(filename string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return formatJSON
+	case ".env":
+		return formatDotenv
+	case ".yml", ".yaml":
+		return formatYaml
+	}
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return formatJSON
+	}
+	var document map[string]interface{}
+	if len(trimmed) == 0 || yaml.Unmarshal(trimmed, &document) == nil {
+		return formatYaml
+	}
+	return formatDotenv
+}
+
+// parseImport parses a plaintext document into a map of name to secret.
+func parseImport(format string, data []byte) (map[string][]byte, error) {
+	switch format {
+	case formatYaml:
+		document, err := yamlDocument(data)
+		if err != nil {
+			return nil, err
+		}
+		return documentSecrets(document)
+	case formatJSON:
+		var document map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&document); err != nil {
+			return nil, err
+		}
+		return documentSecrets(document)
+	case formatDotenv:
+		return parseDotenv(string(data))
+	}
+	return nil, fmt.Errorf("unsupported format '%s'", format)
+}
+
+// documentSecrets converts the scalar values of a YAML or JSON document to secrets. A Kubernetes Secret
+// manifest (as written by export --format kubernetes) is recognized and its data is decoded.
+func documentSecrets(document map[string]interface{}) (map[string][]byte, error) {
+	if document["kind"] == "Secret" && (document["data"] != nil || document["stringData"] != nil) {
+		return kubernetesSecrets(document)
+	}
+	secrets := make(map[string][]byte)
+	for name, value := range document {
+		switch v := value.(type) {
+		case nil:
+			secrets[name] = []byte{}
+		case string:
+			secrets[name] = []byte(v)
+		case bool, int, int64, uint64, float64, json.Number:
+			secrets[name] = []byte(fmt.Sprint(v))
+		default:
+			return nil, fmt.Errorf("%s: only strings, numbers and booleans can be imported", name)
+		}
+	}
+	return secrets, nil
+}
+
+func kubernetesSecrets(document map[string]interface{}) (map[string][]byte, error) {
+	secrets := make(map[string][]byte)
+	for _, field := range []string{"data", "stringData"} {
+		values, _ := document[field].(map[string]interface{})
+		section, err := documentSecrets(values)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range section {
+			if field == "data" {
+				if value, err = base64.StdEncoding.DecodeString(string(value)); err != nil {
+					return nil, fmt.Errorf("%s: %s", name, err)
+				}
+			}
+			secrets[name] = value
+		}
+	}
+	return secrets, nil
+}
+
+// yamlDocument parses a YAML mapping, keeping each scalar as the text it was written as (so that 0755, 1.10
+// and on are imported unchanged) rather than resolving it to a number or boolean. Nested mappings are
+// returned as map[string]interface{}, sequences as []interface{} and nulls as nil.
+func yamlDocument(data []byte) (map[string]interface{}, error) {
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if root.Kind == 0 {
+		return map[string]interface{}{}, nil
+	}
+	value, err := yamlValue(&root)
+	if err != nil {
+		return nil, err
+	}
+	document, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a mapping of names to values")
+	}
+	return document, nil
+}
+
+func yamlValue(node *yamlv3.Node) (interface{}, error) {
+	switch node.Kind {
+	case yamlv3.DocumentNode:
+		return yamlValue(node.Content[0])
+	case yamlv3.AliasNode:
+		return yamlValue(node.Alias)
+	case yamlv3.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			return nil, nil
+		case "!!binary":
+			decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(node.Value), ""))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", node.Line, err)
+			}
+			return string(decoded), nil
+		}
+		return node.Value, nil
+	case yamlv3.SequenceNode:
+		values := make([]interface{}, len(node.Content))
+		for i, item := range node.Content {
+			value, err := yamlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case yamlv3.MappingNode:
+		values := make(map[string]interface{})
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yamlv3.ScalarNode {
+				return nil, fmt.Errorf("line %d: keys must be scalars", key.Line)
+			}
+			if _, duplicate := values[key.Value]; duplicate {
+				return nil, fmt.Errorf("line %d: duplicate key '%s'", key.Line, key.Value)
+			}
+			value, err := yamlValue(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			values[key.Value] = value
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("line %d: unsupported YAML node", node.Line)
+}
+
+// parseDotenv parses NAME=value assignments, one per line. Values may be unquoted, double-quoted (with
+// backslash escapes) or single-quoted (literal, and possibly spanning lines), and quoted segments may be
+// concatenated as in a shell. Lines may be prefixed with "export" and # starts a comment. A name may only be
+// assigned once.
+func parseDotenv(data string) (map[string][]byte, error) {
+	secrets := make(map[string][]byte)
+	lines := make(map[string]int)
+	lineNumber := 1
+	i := 0
+	for i < len(data) {
+		switch c := data[i]; {
+		case c == '\n':
+			lineNumber++
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case c == '#':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			continue
+		}
+
+		start := lineNumber
+		end := strings.IndexAny(data[i:], "=\n")
+		if end < 0 || data[i+end] != '=' {
+			return nil, fmt.Errorf("line %d: expected NAME=value", start)
+		}
+		name := strings.TrimSpace(data[i : i+end])
+		if strings.HasPrefix(name, "export ") || strings.HasPrefix(name, "export\t") {
+			name = strings.TrimSpace(name[len("export"):])
+		}
+		if len(name) == 0 || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("line %d: invalid name '%s'", start, name)
+		}
+		if first, present := lines[name]; present {
+			return nil, fmt.Errorf("line %d: duplicate name '%s', already assigned on line %d", start, name, first)
+		}
+		lines[name] = start
+		i += end + 1
+
+		var value strings.Builder
+		keep := 0 // length of value that must not be trimmed
+	value:
+		for i < len(data) {
+			switch c := data[i]; c {
+			case '\n':
+				break value
+			case '#':
+				if i > 0 && (data[i-1] == ' ' || data[i-1] == '\t') {
+					for i < len(data) && data[i] != '\n' {
+						i++
+					}
+					break value
+				}
+				value.WriteByte(c)
+				i++
+			case '\'':
+				closing := strings.IndexByte(data[i+1:], '\'')
+				if closing < 0 {
+					return nil, fmt.Errorf("line %d: unterminated single quote", start)
+				}
+				quoted := data[i+1 : i+1+closing]
+				lineNumber += strings.Count(quoted, "\n")
+				value.WriteString(quoted)
+				keep = value.Len()
+				i += closing + 2
+			case '"':
+				i++
+				for {
+					if i >= len(data) {
+						return nil, fmt.Errorf("line %d: unterminated double quote", start)
+					}
+					c := data[i]
+					if c == '"' {
+						i++
+						break
+					}
+					if c == '\\' && i+1 < len(data) {
+						i++
+						c = unescapeDotenv(data[i])
+					}
+					if c == '\n' {
+						lineNumber++
+					}
+					value.WriteByte(c)
+					i++
+				}
+				keep = value.Len()
+			case '\\':
+				if i+1 < len(data) && data[i+1] != '\n' {
+					i++
+				}
+				value.WriteByte(data[i])
+				keep = value.Len()
+				i++
+			default:
+				if value.Len() == keep && (c == ' ' || c == '\t') {
+					// Leading whitespace before the value.
+					i++
+					continue
+				}
+				value.WriteByte(c)
+				i++
+			}
+		}
+		unquoted := value.String()
+		secrets[name] = []byte(unquoted[:keep] + strings.TrimRight(unquoted[keep:], " \t\r"))
+	}
+	return secrets, nil
+}
+
+func unescapeDotenv(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	}
+	return c
+}
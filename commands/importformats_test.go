@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectImportFormat(t *testing.T) {
+	assert.Equal(t, formatJSON, detectImportFormat("secrets.json", nil))
+	assert.Equal(t, formatDotenv, detectImportFormat(".env", nil))
+	assert.Equal(t, formatYaml, detectImportFormat("secrets.YML", nil))
+	assert.Equal(t, formatJSON, detectImportFormat("-", []byte(` {"a": "b"}`)))
+	assert.Equal(t, formatYaml, detectImportFormat("-", []byte("a: b\n")))
+	assert.Equal(t, formatDotenv, detectImportFormat("-", []byte("A=b\nB=c\n")))
+}
+
+func TestParseImport(t *testing.T) {
+	secrets, err := parseImport(formatYaml, []byte("a: b\nport: 5432\nenabled: true\nempty:\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("b"), "port": []byte("5432"), "enabled": []byte("true"),
+		"empty": {}}, secrets)
+
+	secrets, err = parseImport(formatJSON, []byte(`{"a": "b", "big": 12345678901234567890}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("b"), "big": []byte("12345678901234567890")}, secrets)
+
+	_, err = parseImport(formatJSON, []byte(`{"nested": {"a": "b"}}`))
+	assert.Error(t, err)
+}
+
+func TestParseImport_yamlScalarsVerbatim(t *testing.T) {
+	secrets, err := parseImport(formatYaml, []byte(`pin: 0755
+version: 1.10
+ssl: on
+big: 12345678901234567890
+float: 1e+300
+hex: 0x1F
+null: ~
+quoted: "0755"
+block: |
+  line one
+  line two
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"pin":     []byte("0755"),
+		"version": []byte("1.10"),
+		"ssl":     []byte("on"),
+		"big":     []byte("12345678901234567890"),
+		"float":   []byte("1e+300"),
+		"hex":     []byte("0x1F"),
+		"null":    {},
+		"quoted":  []byte("0755"),
+		"block":   []byte("line one\nline two\n"),
+	}, secrets)
+
+	for _, invalid := range []string{"nested:\n  a: b\n", "list: [a, b]\n", "a: 1\na: 2\n", "- a\n"} {
+		_, err := parseImport(formatYaml, []byte(invalid))
+		assert.Error(t, err, invalid)
+	}
+
+	secrets, err = parseImport(formatYaml, []byte("kind: Secret\nstringData:\n  pin: 0755\ndata:\n  b: YQ==\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"pin": []byte("0755"), "b": []byte("a")}, secrets)
+}
+
+func TestParseDotenv(t *testing.T) {
+	secrets, err := parseDotenv(`# comment
+PLAIN=value with spaces   # trailing comment
+export EXPORTED='single # quoted'
+DOUBLE="line one\nline \"two\" \$HOME"
+EMPTY=
+CONCATENATED='it'\''s'
+MULTILINE='first
+second'
+HASH=a#b
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"PLAIN":        []byte("value with spaces"),
+		"EXPORTED":     []byte("single # quoted"),
+		"DOUBLE":       []byte("line one\nline \"two\" $HOME"),
+		"EMPTY":        {},
+		"CONCATENATED": []byte("it's"),
+		"MULTILINE":    []byte("first\nsecond"),
+		"HASH":         []byte("a#b"),
+	}, secrets)
+
+	for _, invalid := range []string{"NO_EQUALS\n", "A='unterminated\n", "BAD NAME=x\n", `A="unterminated`} {
+		_, err := parseDotenv(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	_, err = parseDotenv("A=1\nB='two\nlines'\nexport A=3\n")
+	assert.EqualError(t, err, "line 4: duplicate name 'A', already assigned on line 1")
+}
+
+func TestImportReadsExport(t *testing.T) {
+	for _, format := range []string{formatYaml, formatJSON, formatDotenv, formatShell, formatKubernetes} {
+		var output bytes.Buffer
+		assert.NoError(t, writeExport(&output, format, []plaintextSecret{
+			{"API_KEY", []byte("it's \"quoted\" $HOME")},
+			{"DB_PASSWORD", []byte("line one\nline two")},
+		}, "app"))
+		secrets, err := parseImport(detectImportFormat("-", output.Bytes()), output.Bytes())
+		assert.NoError(t, err, format)
+		assert.Equal(t, map[string][]byte{
+			"API_KEY":     []byte("it's \"quoted\" $HOME"),
+			"DB_PASSWORD": []byte("line one\nline two"),
+		}, secrets, format)
+	}
+}
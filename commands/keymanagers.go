@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/primait/biscuit/internal/envelope"
+	"github.com/primait/biscuit/keymanager"
+)
+
+type keyManagerOptionsKey struct{}
+
+// WithKeyManagerOptions returns a copy of ctx that makes the commands run in it configure their key managers
+// with options.
+func WithKeyManagerOptions(ctx context.Context, options keymanager.Options) context.Context {
+	return context.WithValue(ctx, keyManagerOptionsKey{}, options)
+}
+
+// keyManagerOptions returns the options set by WithKeyManagerOptions, or keymanager.DefaultOptions.
+func keyManagerOptions(ctx context.Context) keymanager.Options {
+	if options, ok := ctx.Value(keyManagerOptionsKey{}).(keymanager.Options); ok {
+		return options
+	}
+	return keymanager.DefaultOptions()
+}
+
+// keyManagers returns the source of the key managers used by the commands run in ctx.
+func keyManagers(ctx context.Context) envelope.KeyManagers {
+	return keyManagerOptions(ctx).New
+}
@@ -1,35 +1,205 @@
 package commands
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/primait/biscuit/keymanager"
 	"github.com/primait/biscuit/shared"
 	"github.com/primait/biscuit/store"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
+const (
+	listFormatText = "text"
+	listFormatJSON = "json"
+)
+
 type list struct {
-	filename *string
+	filename      *string
+	long          *bool
+	format        *string
+	missingRegion *string
+}
+
+// secretListing describes how a secret is stored. MatchesTemplate is nil if there is no key template.
+type secretListing struct {
+	Name            string          `json:"name"`
+	KeyManagers     []string        `json:"key_managers"`
+	Regions         []string        `json:"regions"`
+	Algorithms      []string        `json:"algorithms"`
+	MatchesTemplate *bool           `json:"matches_template"`
+	Metadata        *store.Metadata `json:"metadata,omitempty"`
 }
 
 // NewList configures the command to list secrets.
 func NewList(c *kingpin.CmdClause) shared.Command {
-	return &list{filename: shared.FilenameFlag(c)}
+	return &list{
+		filename: shared.FilenameFlag(c),
+		long: c.Flag("long", "Show the key managers, KMS regions and algorithms of each secret, whether it "+
+			"matches the "+store.KeyTemplateName+" template, and its metadata.").Short('l').Bool(),
+		format: c.Flag("format", "Output format. json always includes the details shown by --long. "+
+			"Options: "+listFormatText+", "+listFormatJSON).
+			Default(listFormatText).
+			Enum(listFormatText, listFormatJSON),
+		missingRegion: c.Flag("missing-region", "Only list secrets that cannot be decrypted in REGION "+
+			"because they have no value encrypted under a KMS key there.").PlaceHolder("REGION").String(),
+	}
 }
 
 // Run runs the command.
-func (r *list) Run() error {
-	database := store.NewFileStore(*r.filename)
+func (r *list) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
 
 	entries, err := database.GetAll()
 	if err != nil {
 		return err
 	}
-	for name := range entries {
-		if name == store.KeyTemplateName {
+	region := defaultRegion()
+	var listings []secretListing
+	for _, name := range entries.SecretNames() {
+		if len(*r.missingRegion) > 0 && !missingRegion(entries[name], *r.missingRegion) {
 			continue
 		}
-		fmt.Printf("%s\n", name)
+		listings = append(listings, newSecretListing(name, entries, region))
+	}
+
+	switch {
+	case *r.format == listFormatJSON:
+		if listings == nil {
+			listings = []secretListing{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(listings)
+	case *r.long:
+		return writeLongList(os.Stdout, listings)
+	}
+	for _, listing := range listings {
+		fmt.Printf("%s\n", listing.Name)
 	}
 	return nil
 }
+
+// newSecretListing describes the secret name in entries. region is the region of template keys that do not
+// name one.
+func newSecretListing(name string, entries store.EntryMap, region string) secretListing {
+	values := entries[name]
+	keyManagers := make(map[string]struct{})
+	regions := make(map[string]struct{})
+	algorithms := make(map[string]struct{})
+	for _, value := range values {
+		if len(value.KeyManager) > 0 {
+			keyManagers[value.KeyManager] = struct{}{}
+		}
+		if region := kmsRegion(value.Key); len(region) > 0 {
+			regions[region] = struct{}{}
+		}
+		algorithms[value.Algorithm] = struct{}{}
+	}
+	listing := secretListing{
+		Name:        name,
+		KeyManagers: sortedKeys(keyManagers),
+		Regions:     sortedKeys(regions),
+		Algorithms:  sortedKeys(algorithms),
+		Metadata:    values.Metadata(),
+	}
+	if template, present := entries[store.KeyTemplateName]; present {
+		var keys []store.Key
+		for _, value := range template {
+			keys = append(keys, value.Key)
+		}
+		plan := planRekey(keys, values, region)
+		matches := len(plan.missing) == 0 && len(plan.stale) == 0
+		listing.MatchesTemplate = &matches
+	}
+	return listing
+}
+
+// missingRegion returns true if none of values can be decrypted in region. Values that are not encrypted
+// under a KMS key do not depend on the region, so a secret with any of them is never missing.
+func missingRegion(values store.ValueList, region string) bool {
+	for _, value := range values {
+		if value.KeyManager != keymanager.KmsLabel || kmsRegion(value.Key) == region {
+			return false
+		}
+	}
+	return true
+}
+
+// kmsRegion returns the region of a KMS key, or "" if the key is not a KMS key ARN.
+func kmsRegion(key store.Key) string {
+	if key.KeyManager != keymanager.KmsLabel {
+		return ""
+	}
+	arn, err := keymanager.NewARN(key.KeyID)
+	if err != nil {
+		return ""
+	}
+	return arn.Region
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	sorted := []string{}
+	for key := range set {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// writeLongList writes a table of the secrets and their details.
+func writeLongList(w io.Writer, listings []secretListing) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(table, "NAME\tKEY MANAGERS\tREGIONS\tALGORITHMS\tTEMPLATE\tUPDATED\tBY\tDESCRIPTION\tTAGS\n")
+	for _, listing := range listings {
+		template := "-"
+		if listing.MatchesTemplate != nil && *listing.MatchesTemplate {
+			template = "matches"
+		} else if listing.MatchesTemplate != nil {
+			template = "differs"
+		}
+		updatedAt, updatedBy, description, tags := "-", "-", "", ""
+		if metadata := listing.Metadata; metadata != nil {
+			if !metadata.UpdatedAt.IsZero() {
+				updatedAt = metadata.UpdatedAt.Format(time.RFC3339)
+			}
+			if len(metadata.UpdatedBy) > 0 {
+				updatedBy = metadata.UpdatedBy
+			}
+			description = metadata.Description
+			tags = formatTags(metadata.Tags)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", listing.Name,
+			joinOrDash(listing.KeyManagers), joinOrDash(listing.Regions), joinOrDash(listing.Algorithms),
+			template, updatedAt, updatedBy, description, tags)
+	}
+	return table.Flush()
+}
+
+func joinOrDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ",")
+}
+
+// formatTags formats tags as sorted key=value pairs separated by commas.
+func formatTags(tags map[string]string) string {
+	var pairs []string
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	listTestArn1 = "arn:aws:kms:us-west-1:123456789012:key/37793df5-ad32-4d06-b19f-bfb95cee4a35"
+	listTestArn2 = "arn:aws:kms:us-west-2:123456789012:key/4f2fd7a5-cbd9-4d3a-8a0b-3a3ab7bf0b1d"
+)
+
+func listTestEntries() store.EntryMap {
+	return store.EntryMap{
+		store.KeyTemplateName: {
+			{Key: store.Key{KeyManager: "kms", KeyID: listTestArn1, Algorithm: "secretbox"}},
+			{Key: store.Key{KeyManager: "kms", KeyID: listTestArn2, Algorithm: "secretbox"}},
+		},
+		"both": {
+			{Key: store.Key{KeyManager: "kms", KeyID: listTestArn1, Algorithm: "secretbox"}},
+			{Key: store.Key{KeyManager: "kms", KeyID: listTestArn2, Algorithm: "secretbox"}},
+		},
+		"west-1": {
+			{Key: store.Key{KeyManager: "kms", KeyID: listTestArn1, Algorithm: "aesgcm256"}},
+		},
+		"plaintext": {
+			{Key: store.Key{Algorithm: "none"}},
+		},
+	}
+}
+
+func TestNewSecretListing(t *testing.T) {
+	entries := listTestEntries()
+	listing := newSecretListing("both", entries, "us-west-1")
+	assert.Equal(t, []string{"kms"}, listing.KeyManagers)
+	assert.Equal(t, []string{"us-west-1", "us-west-2"}, listing.Regions)
+	assert.Equal(t, []string{"secretbox"}, listing.Algorithms)
+	assert.True(t, *listing.MatchesTemplate)
+
+	listing = newSecretListing("west-1", entries, "us-west-1")
+	assert.Equal(t, []string{"us-west-1"}, listing.Regions)
+	assert.False(t, *listing.MatchesTemplate)
+
+	listing = newSecretListing("plaintext", entries, "us-west-1")
+	assert.Equal(t, []string{}, listing.KeyManagers)
+	assert.Equal(t, []string{}, listing.Regions)
+	assert.Equal(t, []string{"none"}, listing.Algorithms)
+
+	delete(entries, store.KeyTemplateName)
+	assert.Nil(t, newSecretListing("both", entries, "us-west-1").MatchesTemplate)
+}
+
+func TestMissingRegion(t *testing.T) {
+	entries := listTestEntries()
+	assert.False(t, missingRegion(entries["both"], "us-west-2"))
+	assert.True(t, missingRegion(entries["west-1"], "us-west-2"))
+	assert.False(t, missingRegion(entries["plaintext"], "us-west-2"))
+}
+
+func TestWriteLongList(t *testing.T) {
+	entries := listTestEntries()
+	var output bytes.Buffer
+	assert.NoError(t, writeLongList(&output, []secretListing{
+		newSecretListing("plaintext", entries, "us-west-1"),
+		newSecretListing("west-1", entries, "us-west-1"),
+	}))
+	assert.Equal(t, ""+
+		"NAME       KEY MANAGERS  REGIONS    ALGORITHMS  TEMPLATE  UPDATED  BY  DESCRIPTION  TAGS\n"+
+		"plaintext  -             -          none        differs   -        -                \n"+
+		"west-1     kms           us-west-1  aesgcm256   differs   -        -                \n",
+		output.String())
+}
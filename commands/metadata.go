@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/primait/biscuit/keymanager"
+	"github.com/primait/biscuit/shared"
+	"github.com/primait/biscuit/store"
+)
+
+// callerArn is the ARN of the AWS caller identity, looked up at most once per process.
+var callerArn struct {
+	once sync.Once
+	arn  string
+}
+
+// currentAuthor identifies who is writing a secret, for its updated_by metadata. If any of the keys are
+// managed by KMS, this is the ARN of the AWS caller identity; otherwise, or if the identity cannot be
+// determined, it is the local username.
+func currentAuthor(ctx context.Context, keys []store.Key) string {
+	for _, key := range keys {
+		if key.KeyManager == keymanager.KmsLabel {
+			if arn := lookupCallerArn(ctx, key.KeyID); len(arn) > 0 {
+				return arn
+			}
+			break
+		}
+	}
+	if username := os.Getenv("USER"); len(username) > 0 {
+		return username
+	}
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return ""
+}
+
+// lookupCallerArn returns the ARN of the AWS caller identity, asking STS in the region of keyID the first time
+// it is called, or "" if the call fails. The call is bounded by the key managers' CallTimeout.
+func lookupCallerArn(ctx context.Context, keyID string) string {
+	callerArn.once.Do(func() {
+		session := shared.GetNewSession()
+		if arn, err := keymanager.NewARN(keyID); err == nil {
+			session = shared.GetNewSessionWithRegion(arn.Region)
+		}
+		if timeout := keyManagerOptions(ctx).CallTimeout; timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		if identity, err := sts.New(session).GetCallerIdentityWithContext(ctx, nil); err == nil {
+			callerArn.arn = *identity.Arn
+		}
+	})
+	return callerArn.arn
+}
+
+// touchMetadata returns a copy of previous, which may be nil, recording that the secret was just written
+// by author.
+func touchMetadata(previous *store.Metadata, author string) *store.Metadata {
+	metadata := &store.Metadata{}
+	if previous != nil {
+		*metadata = *previous
+	}
+	metadata.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	metadata.UpdatedBy = author
+	return metadata
+}
+
+// setMetadata sets the metadata of each of the values and returns them.
+func setMetadata(values store.ValueList, metadata *store.Metadata) store.ValueList {
+	for i := range values {
+		values[i].Metadata = metadata
+	}
+	return values
+}
@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTouchMetadata(t *testing.T) {
+	metadata := touchMetadata(nil, "oreilly")
+	assert.Equal(t, "oreilly", metadata.UpdatedBy)
+	assert.WithinDuration(t, time.Now(), metadata.UpdatedAt, time.Minute)
+
+	previous := &store.Metadata{UpdatedBy: "oreilly", Description: "launch codes",
+		Tags: map[string]string{"team": "ops"}}
+	metadata = touchMetadata(previous, "gordon")
+	assert.Equal(t, "gordon", metadata.UpdatedBy)
+	assert.Equal(t, "launch codes", metadata.Description)
+	assert.Equal(t, previous.Tags, metadata.Tags)
+	assert.Equal(t, "oreilly", previous.UpdatedBy)
+}
+
+func TestFormatTags(t *testing.T) {
+	assert.Equal(t, "", formatTags(nil))
+	assert.Equal(t, "env=prod,team=ops", formatTags(map[string]string{"team": "ops", "env": "prod"}))
+}
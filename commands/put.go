@@ -1,15 +1,15 @@
 package commands
 
 import (
-	"encoding/base64"
+	"context"
 	"errors"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
 
-	"sync"
-
 	"github.com/primait/biscuit/algorithms"
+	"github.com/primait/biscuit/internal/envelope"
 	"github.com/primait/biscuit/keymanager"
 	"github.com/primait/biscuit/shared"
 	"github.com/primait/biscuit/store"
@@ -18,13 +18,17 @@ import (
 
 // Put implements the "put" command.
 type put struct {
-	keyID      *string
-	keyManager *string
-	name       *string
-	fromFile   **os.File
-	value      *string
-	algo       *string
-	filename   *string
+	keyID       *string
+	keyManager  *string
+	name        *string
+	fromFile    **os.File
+	value       *string
+	algo        *string
+	filename    *string
+	description *string
+	tags        *map[string]string
+	fields      *map[string]string
+	contentType *string
 }
 
 var (
@@ -33,6 +37,7 @@ var (
 	errConflictingValue = errors.New(
 		"Please specify either a secret in a positional argument, or use --from-file, " +
 			"but not both.")
+	errConflictingField = errors.New("Please specify either a secret or --field, but not both.")
 )
 
 // NewPut configures the command for storing secrets.
@@ -43,7 +48,9 @@ func NewPut(c *kingpin.CmdClause) shared.Command {
 			"AWS_REGION is set). If --key-id is not set, the "+store.KeyTemplateName+" "+
 			"entry from FILE will be used "+
 			"(if present).").Short('k').String()
-	write.keyManager = c.Flag("key-manager", "Source of envelope encryption keys. Options: "+
+	write.keyManager = c.Flag("key-manager", "Source of envelope encryption keys for key IDs whose format "+
+		"does not identify one. KMS ARNs, age recipients (age1...) and argon2id: key IDs always use their own "+
+		"key manager. Options: "+
 		strings.Join(keymanager.GetKeyManagers(), ", ")).
 		Default(keymanager.GetDefaultKeyManager()).Short('p').Enum(keymanager.GetKeyManagers()...)
 	write.name = c.Arg("name", "Name of the secret.").Required().String()
@@ -52,49 +59,61 @@ func NewPut(c *kingpin.CmdClause) shared.Command {
 		"of the command line.").PlaceHolder("FILE").Short('i').File()
 	write.algo = shared.AlgorithmFlag(c)
 	write.filename = shared.FilenameFlag(c)
+	write.description = c.Flag("description", "Describe the secret. If not set, the existing description "+
+		"is kept.").String()
+	write.tags = c.Flag("tag", "Tag the secret, replacing any existing tags. May be repeated.").
+		PlaceHolder("KEY=VALUE").StringMap()
+	write.fields = c.Flag("field", "Set the string field at PATH of a JSON or YAML secret to VALUE, "+
+		"leaving the rest of the secret unchanged. PATH is a list of keys and array indexes separated by "+
+		"dots. May be repeated.").PlaceHolder("PATH=VALUE").StringMap()
+	write.contentType = c.Flag("content-type", "Record the format of the secret. If not set, the existing "+
+		"content type is kept. Options: "+strings.Join(contentTypes, ", ")).Enum(contentTypes...)
 
 	return write
 }
 
-type encryptResult struct {
-	value store.Value
-	err   error
-}
-
 // Run runs the command.
-func (w *put) Run() error {
-	database := store.NewFileStore(*w.filename)
+func (w *put) Run(ctx context.Context) error {
+	database, err := store.Open(*w.filename)
+	if err != nil {
+		return err
+	}
 
 	keys, err := w.chooseKeys(database)
 	if err != nil {
 		return err
 	}
 
-	plaintext, err := w.choosePlaintext()
+	previous, version, err := database.GetVersion(*w.name)
+	if err != nil && err != store.ErrNameNotFound && !store.IsProbablyNewStore(err) {
+		return err
+	}
+	contentType := *w.contentType
+	var plaintext []byte
+	if len(*w.fields) > 0 {
+		plaintext, contentType, err = w.updateFields(ctx, previous, contentType)
+	} else {
+		plaintext, err = w.choosePlaintext()
+	}
 	if err != nil {
 		return err
 	}
 
-	results := make(chan encryptResult, len(keys))
-	var wg sync.WaitGroup
-	for _, keyConfig := range keys {
-		wg.Add(1)
-		go func(keyConfig store.Key, plaintext []byte) {
-			defer wg.Done()
-			value, err := encryptOne(keyConfig, *w.name, plaintext)
-			results <- encryptResult{value, err}
-		}(keyConfig, plaintext)
+	valueList, err := encryptAll(ctx, keys, *w.name, plaintext)
+	if err != nil {
+		return err
 	}
-	wg.Wait()
-	close(results)
-
-	var valueList []store.Value
-	for value := range results {
-		if value.err != nil {
-			return value.err
-		}
-		valueList = append(valueList, value.value)
+	metadata := touchMetadata(previous.Metadata(), currentAuthor(ctx, keys))
+	if len(contentType) > 0 {
+		metadata.ContentType = contentType
+	}
+	if len(*w.description) > 0 {
+		metadata.Description = *w.description
 	}
+	if len(*w.tags) > 0 {
+		metadata.Tags = *w.tags
+	}
+	updates := store.EntryMap{*w.name: setMetadata(valueList, metadata)}
 
 	// If the file doesn't have a template, create one from the keys used here.
 	if _, err := database.Get(store.KeyTemplateName); store.IsProbablyNewStore(err) {
@@ -102,22 +121,20 @@ func (w *put) Run() error {
 		for _, key := range keys {
 			values = append(values, store.Value{Key: key})
 		}
-		err := database.Put(store.KeyTemplateName, values)
-		if err != nil {
-			return err
-		}
+		updates[store.KeyTemplateName] = values
 	}
 
-	return database.Put(*w.name, valueList)
+	// The new value is derived from the previous one, so it must not replace a value written meanwhile.
+	return database.PutAllIfVersion(version, updates)
 }
 
-func (w *put) chooseKeys(database store.FileStore) ([]store.Key, error) {
+func (w *put) chooseKeys(database store.Store) ([]store.Key, error) {
 	if len(*w.keyID) > 0 {
 		var keys []store.Key
 		split := strings.Split(*w.keyID, ",")
 		for _, key := range split {
 			keys = append(keys, store.Key{
-				KeyManager: *w.keyManager,
+				KeyManager: keymanager.ForKeyID(key, *w.keyManager),
 				KeyID:      key,
 				Algorithm:  *w.algo})
 		}
@@ -151,33 +168,57 @@ func (w *put) choosePlaintext() ([]byte, error) {
 	return []byte(*w.value), nil
 }
 
-func encryptOne(keyConfig store.Key, name string, plaintext []byte) (store.Value, error) {
-	var value store.Value
-	algo, err := algorithms.New(keyConfig.Algorithm)
-	if err != nil {
-		return value, err
-	}
-	value.Algorithm = algo.Label()
+// encryptAll encrypts plaintext under each of the keys in parallel.
+func encryptAll(ctx context.Context, keys []store.Key, name string, plaintext []byte) (store.ValueList, error) {
+	return envelope.EncryptAll(ctx, keyManagers(ctx), keys, name, plaintext)
+}
+
+func encryptOne(ctx context.Context, keyConfig store.Key, name string, plaintext []byte) (store.Value, error) {
+	return envelope.Encrypt(ctx, keyManagers(ctx), keyConfig, name, plaintext)
+}
 
-	var envelopeKey keymanager.EnvelopeKey
-	if algo.NeedsKey() {
-		keyManager, err := keymanager.New(keyConfig.KeyManager)
-		if err != nil {
-			return value, err
+// updateFields decrypts the existing secret, or starts from an empty object if there is none, and sets each
+// of the fields. It returns the new plaintext and its content type.
+func (w *put) updateFields(ctx context.Context, previous store.ValueList, contentType string) ([]byte, string, error) {
+	if *w.fromFile != nil || len(*w.value) > 0 {
+		return nil, "", errConflictingField
+	}
+	if len(contentType) == 0 && previous.Metadata() != nil {
+		contentType = previous.Metadata().ContentType
+	}
+	var original []byte
+	var document interface{}
+	if len(previous) > 0 {
+		var err error
+		if original, err = decryptAnyValue(ctx, previous, *w.name); err != nil {
+			return nil, "", err
 		}
-		value.KeyManager = keyManager.Label()
-		envelopeKey, err = keyManager.GenerateEnvelopeKey(keyConfig.KeyID, name)
-		if err != nil {
-			return value, err
+		if document, contentType, err = parseStructured(original, contentType); err != nil {
+			return nil, "", err
 		}
-		value.KeyID = envelopeKey.ResolvedID
-		value.KeyCiphertext = base64.StdEncoding.EncodeToString(envelopeKey.Ciphertext)
+	} else if contentType == contentTypeText {
+		return nil, "", errNotStructured
+	} else {
+		if len(contentType) == 0 {
+			contentType = contentTypeJSON
+		}
+		document = newStructured(contentType)
 	}
 
-	ciphertext, err := algo.Encrypt(envelopeKey.Plaintext, plaintext)
-	if err != nil {
-		return value, err
+	var paths []string
+	for path := range *w.fields {
+		if len(path) == 0 {
+			return nil, "", errors.New("--field: the path must not be empty")
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		var err error
+		if document, err = setField(document, path, (*w.fields)[path]); err != nil {
+			return nil, "", err
+		}
 	}
-	value.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext)
-	return value, nil
+	plaintext, err := marshalStructured(document, contentType, original)
+	return plaintext, contentType, err
 }
@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptOne_bindsName(t *testing.T) {
+	// The testing key manager returns the same data key for every secret, so only the algorithm can tell
+	// that a value was moved to another name.
+	ctx := context.Background()
+	key := store.Key{KeyManager: "testing", KeyID: "testing", Algorithm: "aesgcm256-v2"}
+	password, err := encryptOne(ctx, key, "password", []byte("god"))
+	require.NoError(t, err)
+	username, err := encryptOne(ctx, key, "username", []byte("oreilly"))
+	require.NoError(t, err)
+
+	plaintext, err := decryptOneValue(ctx, password, "password")
+	require.NoError(t, err)
+	assert.Equal(t, "god", string(plaintext))
+	_, err = decryptOneValue(ctx, password, "username")
+	assert.Error(t, err)
+	_, err = decryptOneValue(ctx, username, "password")
+	assert.Error(t, err)
+}
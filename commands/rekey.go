@@ -0,0 +1,243 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/primait/biscuit/keymanager"
+	"github.com/primait/biscuit/shared"
+	"github.com/primait/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type rekey struct {
+	filename       *string
+	regionPriority *[]string
+	prune, dryRun  *bool
+	parallelism    *int
+}
+
+// NewRekey configures the command to reconcile secrets with the key template.
+func NewRekey(c *kingpin.CmdClause) shared.Command {
+	return &rekey{
+		prune: c.Flag("prune", "Also remove values encrypted under keys that are no longer in the "+
+			store.KeyTemplateName+" entry.").Bool(),
+		dryRun:         c.Flag("dry-run", "Print what would change without changing anything.").Bool(),
+		parallelism:    shared.ParallelismFlag(c),
+		regionPriority: shared.AwsRegionPriorityFlag(c),
+		filename:       shared.FilenameFlag(c),
+	}
+}
+
+// rekeyPlan describes the changes needed to make a secret consistent with the template.
+type rekeyPlan struct {
+	missing []store.Key
+	stale   store.ValueList
+	keep    store.ValueList
+}
+
+// planRekey compares values with the template keys. region is the region of template keys that do not name
+// one; see defaultRegion.
+func planRekey(keys []store.Key, values store.ValueList, region string) rekeyPlan {
+	var plan rekeyPlan
+	for _, key := range keys {
+		found := false
+		for _, value := range values {
+			if keyMatches(key, value.Key, region) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			plan.missing = append(plan.missing, key)
+		}
+	}
+	for _, value := range values {
+		found := false
+		for _, key := range keys {
+			if keyMatches(key, value.Key, region) {
+				found = true
+				break
+			}
+		}
+		if found {
+			plan.keep = append(plan.keep, value)
+		} else {
+			plan.stale = append(plan.stale, value)
+		}
+	}
+	return plan
+}
+
+// Run the command.
+func (r *rekey) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	keys, err := database.GetKeyIds()
+	if err != nil {
+		return err
+	}
+	entries, version, err := database.GetAllVersion()
+	if err != nil {
+		return err
+	}
+	names := entries.SecretNames()
+	region := defaultRegion()
+
+	var mu sync.Mutex
+	updated := make(store.EntryMap)
+	reports := make(map[string]string)
+	failures := 0
+	forEachParallel(names, *r.parallelism, func(name string) {
+		values := entries[name]
+		plan := planRekey(keys, values, region)
+		report, valueList, err := r.rekeyOne(ctx, name, values, plan)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: unable to rekey: %s\n", name, err)
+			failures++
+			return
+		}
+		reports[name] = report
+		if valueList != nil {
+			updated[name] = valueList
+		}
+	})
+
+	if len(updated) > 0 && !*r.dryRun {
+		if err := database.PutAllIfVersion(version, updated); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		if report, present := reports[name]; present {
+			fmt.Printf("%s: %s\n", name, report)
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d of %d secrets could not be rekeyed", failures, len(names))
+	}
+	return nil
+}
+
+// rekeyOne applies plan to a single secret. It returns a description of the changes and the new
+// ValueList, or a nil ValueList if nothing needs to be written.
+func (r *rekey) rekeyOne(ctx context.Context, name string, values store.ValueList, plan rekeyPlan) (string,
+	store.ValueList, error) {
+	var changes []string
+	if len(plan.missing) > 0 {
+		changes = append(changes, "added "+describeKeyLocations(plan.missing))
+	}
+	if len(plan.stale) > 0 {
+		var staleKeys []store.Key
+		for _, value := range plan.stale {
+			staleKeys = append(staleKeys, value.Key)
+		}
+		if *r.prune {
+			changes = append(changes, "removed "+describeKeyLocations(staleKeys))
+		} else {
+			changes = append(changes, "kept stale "+describeKeyLocations(staleKeys))
+		}
+	}
+	if len(plan.missing) == 0 && (len(plan.stale) == 0 || !*r.prune) {
+		if len(changes) == 0 {
+			return "up to date", nil, nil
+		}
+		return strings.Join(changes, "; "), nil, nil
+	}
+	if *r.dryRun {
+		return "would have " + strings.Join(changes, "; "), nil, nil
+	}
+
+	valueList := values
+	if *r.prune {
+		valueList = plan.keep
+	}
+	if len(plan.missing) > 0 {
+		sorted := make(store.ValueList, len(values))
+		copy(sorted, values)
+		store.SortByKmsRegion(*r.regionPriority)(sorted)
+		plaintext, err := decryptAnyValue(ctx, sorted, name)
+		if err != nil {
+			return "", nil, err
+		}
+		added, err := encryptAll(ctx, plan.missing, name, plaintext)
+		if err != nil {
+			return "", nil, err
+		}
+		valueList = append(append(store.ValueList{}, valueList...), added...)
+	}
+	return strings.Join(changes, "; "), setMetadata(valueList, values.Metadata()), nil
+}
+
+// keyMatches returns true if value appears to have been encrypted under the template key. Templates may refer to
+// KMS keys by alias or by bare key ID, whereas values always hold the resolved key ARN, so KMS keys are compared
+// by region and account when the IDs are not identical. Template keys that do not name a region are in region.
+func keyMatches(template, value store.Key, region string) bool {
+	if template.KeyManager != value.KeyManager {
+		return false
+	}
+	if template.KeyID == value.KeyID {
+		return true
+	}
+	if template.KeyManager == keymanager.PassphraseLabel {
+		// Without explicit parameters and salt, every put generates a fresh salt.
+		return !strings.HasPrefix(template.KeyID, "argon2id:")
+	}
+	if template.KeyManager != keymanager.KmsLabel {
+		return false
+	}
+	valueArn, err := keymanager.NewARN(value.KeyID)
+	if err != nil {
+		return false
+	}
+	templateArn, err := keymanager.NewARN(template.KeyID)
+	if err != nil {
+		// A bare key ID (ex: 37793df5-...) or alias (ex: alias/biscuit-default) with the region implied by
+		// AWS_REGION. Aliases can't be resolved without calling KMS, so they are assumed to match any key in
+		// that region.
+		if valueArn.Region != region {
+			return false
+		}
+		return template.KeyID == valueArn.Resource || strings.HasPrefix(template.KeyID, "alias/")
+	}
+	if templateArn.Region != valueArn.Region || templateArn.AccountID != valueArn.AccountID {
+		return false
+	}
+	return templateArn.IsKmsAlias() || templateArn.Resource == valueArn.Resource
+}
+
+// defaultRegion returns the region of KMS keys that are named without one, from AWS_REGION or the AWS
+// configuration files.
+func defaultRegion() string {
+	return aws.StringValue(shared.GetNewSession().Config.Region)
+}
+
+// describeKeyLocations summarizes where keys live, ex: "kms us-east-1, us-west-2".
+func describeKeyLocations(keys []store.Key) string {
+	var locations []string
+	for _, key := range keys {
+		locations = append(locations, keyLocation(key))
+	}
+	return strings.Join(locations, ", ")
+}
+
+// keyLocation returns a short description of a key, such as the KMS region.
+func keyLocation(key store.Key) string {
+	if key.KeyManager == keymanager.KmsLabel {
+		if arn, err := keymanager.NewARN(key.KeyID); err == nil {
+			return key.KeyManager + " " + arn.Region
+		}
+	}
+	if len(key.KeyManager) == 0 {
+		return key.Algorithm
+	}
+	return key.KeyManager + " " + key.KeyID
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyMatches(t *testing.T) {
+	west1Key := store.Key{KeyManager: "kms",
+		KeyID: "arn:aws:kms:us-west-1:922329555442:key/8a97cd86-54c8-4964-b9b3-4d5d6ae98139"}
+	tests := []struct {
+		template string
+		region   string
+		expected bool
+	}{
+		{"arn:aws:kms:us-west-1:922329555442:key/8a97cd86-54c8-4964-b9b3-4d5d6ae98139", "us-east-1", true},
+		{"arn:aws:kms:us-west-1:922329555442:alias/biscuit-default", "us-east-1", true},
+		{"8a97cd86-54c8-4964-b9b3-4d5d6ae98139", "us-west-1", true},
+		{"8a97cd86-54c8-4964-b9b3-4d5d6ae98139", "us-east-1", false},
+		{"alias/biscuit-default", "us-west-1", true},
+		{"alias/biscuit-default", "us-east-1", false},
+		{"alias/biscuit-default", "", false},
+		{"arn:aws:kms:us-west-2:922329555442:alias/biscuit-default", "us-west-1", false},
+		{"arn:aws:kms:us-west-1:105770556716:alias/biscuit-default", "us-west-1", false},
+		{"arn:aws:kms:us-west-1:922329555442:key/0f809ad7-ecd3-41a3-9d21-923195530c8a", "us-west-1", false},
+		{"0f809ad7-ecd3-41a3-9d21-923195530c8a", "us-west-1", false},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, keyMatches(store.Key{KeyManager: "kms", KeyID: tc.template}, west1Key, tc.region),
+			"%s in %s", tc.template, tc.region)
+	}
+	assert.False(t, keyMatches(store.Key{KeyManager: "testing", KeyID: west1Key.KeyID}, west1Key, "us-west-1"))
+
+	passphraseKey := store.Key{KeyManager: "passphrase",
+		KeyID: "argon2id:v=19,m=65536,t=3,p=4:c2FsdHNhbHRzYWx0c2FsdA"}
+	assert.True(t, keyMatches(store.Key{KeyManager: "passphrase", KeyID: "argon2id"}, passphraseKey, ""))
+	assert.True(t, keyMatches(passphraseKey, passphraseKey, ""))
+	assert.False(t, keyMatches(store.Key{KeyManager: "passphrase",
+		KeyID: "argon2id:v=19,m=65536,t=3,p=4:b3RoZXJzYWx0"}, passphraseKey, ""))
+}
+
+func TestPlanRekey(t *testing.T) {
+	west1 := store.Key{KeyManager: "kms", KeyID: "arn:aws:kms:us-west-1:922329555442:alias/biscuit-default"}
+	east1 := store.Key{KeyManager: "kms", KeyID: "arn:aws:kms:us-east-1:922329555442:alias/biscuit-default"}
+	west1Value := store.Value{Key: store.Key{KeyManager: "kms",
+		KeyID: "arn:aws:kms:us-west-1:922329555442:key/8a97cd86-54c8-4964-b9b3-4d5d6ae98139"}}
+	west2Value := store.Value{Key: store.Key{KeyManager: "kms",
+		KeyID: "arn:aws:kms:us-west-2:922329555442:key/0f809ad7-ecd3-41a3-9d21-923195530c8a"}}
+
+	plan := planRekey([]store.Key{west1, east1}, store.ValueList{west1Value, west2Value}, "us-west-1")
+	assert.Equal(t, []store.Key{east1}, plan.missing)
+	assert.Equal(t, store.ValueList{west2Value}, plan.stale)
+	assert.Equal(t, store.ValueList{west1Value}, plan.keep)
+}
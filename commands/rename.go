@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/primait/biscuit/shared"
+	"github.com/primait/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var errCannotRenameTemplate = errors.New("The " + store.KeyTemplateName + " entry cannot be renamed, and no " +
+	"secret may be renamed to " + store.KeyTemplateName + ".")
+
+type rename struct {
+	oldName, newName, filename *string
+	overwrite                  *bool
+}
+
+// NewRename configures the command to rename a secret.
+func NewRename(c *kingpin.CmdClause) shared.Command {
+	return &rename{
+		oldName:   c.Arg("old", "Current name of the secret.").Required().String(),
+		newName:   c.Arg("new", "New name of the secret.").Required().String(),
+		overwrite: c.Flag("overwrite", "Replace the secret named NEW if it already exists.").Bool(),
+		filename:  shared.FilenameFlag(c),
+	}
+}
+
+// Run the command.
+func (r *rename) Run(ctx context.Context) error {
+	if *r.oldName == store.KeyTemplateName || *r.newName == store.KeyTemplateName {
+		return errCannotRenameTemplate
+	}
+	if *r.oldName == *r.newName {
+		return nil
+	}
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	entries, version, err := database.GetAllVersion()
+	if err != nil {
+		return err
+	}
+	values, present := entries[*r.oldName]
+	if !present {
+		return fmt.Errorf("%s: %s", *r.oldName, store.ErrNameNotFound)
+	}
+	if _, present := entries[*r.newName]; present && !*r.overwrite {
+		return fmt.Errorf("%s already exists. Use --overwrite to replace it.", *r.newName)
+	}
+
+	// The name is bound into the encryption context of each key ciphertext, so every value has to be
+	// decrypted under the old name and encrypted again under the same key with the new name. The values keep
+	// their order, so that the file only changes where it must.
+	valueList := make(store.ValueList, len(values))
+	errs := make([]error, len(values))
+	var wg sync.WaitGroup
+	for i, value := range values {
+		wg.Add(1)
+		go func(i int, value store.Value) {
+			defer wg.Done()
+			plaintext, err := decryptOneValue(ctx, value, *r.oldName)
+			if err != nil {
+				errs[i] = fmt.Errorf("decryption under %s %s failed: %s", value.KeyManager, value.KeyID, err)
+				return
+			}
+			valueList[i], errs[i] = encryptOne(ctx, value.Key, *r.newName, plaintext)
+		}(i, value)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	// Write the new name and remove the old one together, so that an interruption cannot leave both.
+	return database.PutAllIfVersion(version, store.EntryMap{*r.newName: setMetadata(valueList, values.Metadata())},
+		*r.oldName)
+}
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/primait/biscuit/shared"
+	"github.com/primait/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type render struct {
+	source, output, filename *string
+	regionPriority           *[]string
+}
+
+// NewRender configures the command to substitute secrets into a template.
+func NewRender(c *kingpin.CmdClause) shared.Command {
+	return &render{
+		source: c.Arg("template", "Go text/template to render, or - for stdin.").Required().String(),
+		output: c.Flag("output", "Write to FILE instead of stdout. The file is only readable by its owner.").
+			PlaceHolder("FILE").
+			Short('o').
+			String(),
+		regionPriority: shared.AwsRegionPriorityFlag(c),
+		filename:       shared.FilenameFlag(c),
+	}
+}
+
+// Run the command.
+func (r *render) Run(ctx context.Context) error {
+	var text []byte
+	var err error
+	if *r.source == "-" {
+		text, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		text, err = ioutil.ReadFile(*r.source)
+	}
+	if err != nil {
+		return err
+	}
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New(filepath.Base(*r.source)).
+		Option("missingkey=error").
+		Funcs(renderFuncs(ctx, database, *r.regionPriority)).
+		Parse(string(text))
+	if err != nil {
+		return err
+	}
+	// Render the whole template before writing anything so that a failure does not leave partial output.
+	var output bytes.Buffer
+	if err := tmpl.Execute(&output, nil); err != nil {
+		return err
+	}
+
+	if len(*r.output) > 0 {
+		return writePrivateFile(*r.output, output.Bytes())
+	}
+	warnIfStdoutShared()
+	_, err = os.Stdout.Write(output.Bytes())
+	return err
+}
+
+// renderFuncs returns the functions available to templates. secret decrypts a secret the first time it is
+// used, as get does, and fails if there is no secret with that name.
+func renderFuncs(ctx context.Context, database store.Store, regionPriority []string) template.FuncMap {
+	plaintexts := make(map[string]string)
+	return template.FuncMap{
+		"secret": func(name string) (string, error) {
+			if plaintext, present := plaintexts[name]; present {
+				return plaintext, nil
+			}
+			if name == store.KeyTemplateName {
+				return "", fmt.Errorf("%s: %s", name, store.ErrNameNotFound)
+			}
+			values, err := database.Get(name)
+			if err != nil {
+				return "", fmt.Errorf("%s: %s", name, err)
+			}
+			store.SortByKmsRegion(regionPriority)(values)
+			plaintext, err := decryptAnyValue(ctx, values, name)
+			if err != nil {
+				return "", fmt.Errorf("%s: %s", name, err)
+			}
+			plaintexts[name] = string(plaintext)
+			return plaintexts[name], nil
+		},
+		"base64": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"json": func(v interface{}) (string, error) {
+			encoded, err := json.Marshal(v)
+			return string(encoded), err
+		},
+	}
+}
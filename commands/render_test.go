@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderFuncs(t *testing.T) {
+	database, err := store.Open(filepath.Join(t.TempDir(), "secrets.yml"))
+	require.NoError(t, err)
+	values, err := encryptAll(context.Background(), []store.Key{{Algorithm: "none"}}, "password", []byte(`p"w`))
+	require.NoError(t, err)
+	require.NoError(t, database.Put("password", values))
+
+	execute := func(text string) (string, error) {
+		var output bytes.Buffer
+		tmpl := template.Must(template.New("test").Funcs(renderFuncs(context.Background(), database, nil)).Parse(text))
+		err := tmpl.Execute(&output, nil)
+		return output.String(), err
+	}
+
+	output, err := execute(`{{ secret "password" }} {{ secret "password" | base64 }} {{ secret "password" | json }}`)
+	require.NoError(t, err)
+	assert.Equal(t, `p"w cCJ3 "p\"w"`, output)
+
+	_, err = execute(`{{ secret "missing" }}`)
+	assert.Contains(t, err.Error(), "missing: "+store.ErrNameNotFound.Error())
+	_, err = execute(`{{ secret "` + store.KeyTemplateName + `" }}`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,186 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/primait/biscuit/algorithms"
+	"github.com/primait/biscuit/shared"
+	"github.com/primait/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type rotate struct {
+	name, filename, algo *string
+	regionPriority       *[]string
+	dryRun, prune        *bool
+	parallelism          *int
+}
+
+// NewRotate configures the command to re-encrypt secrets under fresh data keys.
+func NewRotate(c *kingpin.CmdClause) shared.Command {
+	return &rotate{
+		name: c.Arg("name", "Name of the secret to rotate. If omitted, all secrets are rotated.").String(),
+		algo: c.Flag("algorithm", "Re-encrypt using this algorithm instead of the one in the "+
+			store.KeyTemplateName+" entry. Options: "+strings.Join(algorithms.GetAlgorithms(), ", ")).
+			Short('a').
+			Enum(algorithms.GetAlgorithms()...),
+		dryRun: c.Flag("dry-run", "Print what would be rotated without changing anything.").Bool(),
+		prune: c.Flag("prune", "Remove values whose keys are not in the "+store.KeyTemplateName+" entry. "+
+			"Otherwise they are rotated under their own keys.").Bool(),
+		parallelism:    shared.ParallelismFlag(c),
+		regionPriority: shared.AwsRegionPriorityFlag(c),
+		filename:       shared.FilenameFlag(c),
+	}
+}
+
+// Run the command.
+func (r *rotate) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	keys, err := database.GetKeyIds()
+	if err != nil {
+		return err
+	}
+	if len(*r.algo) > 0 {
+		for i := range keys {
+			keys[i].Algorithm = *r.algo
+		}
+	}
+	entries, version, err := database.GetAllVersion()
+	if err != nil {
+		return err
+	}
+	names := entries.SecretNames()
+	if len(*r.name) > 0 {
+		if *r.name == store.KeyTemplateName {
+			return errors.New("The " + store.KeyTemplateName + " entry does not contain a secret.")
+		}
+		if _, present := entries[*r.name]; !present {
+			return store.ErrNameNotFound
+		}
+		names = []string{*r.name}
+	}
+
+	region := defaultRegion()
+	if *r.dryRun {
+		for _, name := range names {
+			secretKeys, dropped := rotationKeys(keys, entries[name], *r.algo, region, *r.prune)
+			fmt.Printf("%s: %s -> %s%s\n", name, describeValues(entries[name]), describeKeys(secretKeys),
+				describeDropped(dropped))
+		}
+		return nil
+	}
+
+	var mu sync.Mutex
+	rotated := make(store.EntryMap)
+	removed := make(map[string][]store.Key)
+	failures := 0
+	forEachParallel(names, *r.parallelism, func(name string) {
+		values := entries[name]
+		store.SortByKmsRegion(*r.regionPriority)(values)
+		secretKeys, dropped := rotationKeys(keys, values, *r.algo, region, *r.prune)
+		valueList, err := rotateOne(ctx, secretKeys, name, values)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: unable to rotate: %s\n", name, err)
+			failures++
+			return
+		}
+		rotated[name] = valueList
+		removed[name] = dropped
+	})
+
+	if len(rotated) > 0 {
+		// Fail rather than overwrite a secret that was put while the others were being rotated.
+		if err := database.PutAllIfVersion(version, rotated); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		if valueList, present := rotated[name]; present {
+			fmt.Printf("%s: rotated (%s)%s\n", name, describeValues(valueList), describeDropped(removed[name]))
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d of %d secrets could not be rotated", failures, len(names))
+	}
+	return nil
+}
+
+func rotateOne(ctx context.Context, keys []store.Key, name string, values store.ValueList) (store.ValueList, error) {
+	plaintext, err := decryptAnyValue(ctx, values, name)
+	if err != nil {
+		return nil, err
+	}
+	valueList, err := encryptAll(ctx, keys, name, plaintext)
+	return setMetadata(valueList, values.Metadata()), err
+}
+
+// rotationKeys returns the keys to re-encrypt a secret under: the template keys, followed by the keys of any
+// values that do not match them, including plaintext values. With prune, those values are dropped instead and
+// their keys are returned as dropped. algo, if set, replaces the algorithm of the kept keys, except for
+// plaintext values. region is the region of template keys that do not name one.
+func rotationKeys(template []store.Key, values store.ValueList, algo, region string, prune bool) (keys,
+	dropped []store.Key) {
+	keys = append(keys, template...)
+	for _, value := range planRekey(template, values, region).stale {
+		key := value.Key
+		if prune {
+			dropped = append(dropped, key)
+			continue
+		}
+		if len(algo) > 0 && len(key.KeyManager) > 0 {
+			key.Algorithm = algo
+		}
+		keys = append(keys, key)
+	}
+	return keys, dropped
+}
+
+// describeDropped describes the keys removed by --prune, or returns "" if there are none.
+func describeDropped(dropped []store.Key) string {
+	if len(dropped) == 0 {
+		return ""
+	}
+	return "; removed " + describeKeyLocations(dropped)
+}
+
+// describeValues summarizes a ValueList, ex: "2 values (kms/secretbox)".
+func describeValues(values store.ValueList) string {
+	var keys []store.Key
+	for _, value := range values {
+		keys = append(keys, value.Key)
+	}
+	return describeKeys(keys)
+}
+
+// describeKeys summarizes a list of keys, ex: "2 values (kms/secretbox)".
+func describeKeys(keys []store.Key) string {
+	kinds := make(map[string]struct{})
+	for _, key := range keys {
+		if len(key.KeyManager) > 0 {
+			kinds[key.KeyManager+"/"+key.Algorithm] = struct{}{}
+		} else {
+			kinds[key.Algorithm] = struct{}{}
+		}
+	}
+	var sorted []string
+	for kind := range kinds {
+		sorted = append(sorted, kind)
+	}
+	sort.Strings(sorted)
+	noun := "values"
+	if len(keys) == 1 {
+		noun = "value"
+	}
+	return fmt.Sprintf("%d %s (%s)", len(keys), noun, strings.Join(sorted, ", "))
+}
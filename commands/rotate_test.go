@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotationKeys(t *testing.T) {
+	west1 := store.Key{KeyManager: "kms", KeyID: "arn:aws:kms:us-west-1:922329555442:alias/biscuit-default",
+		Algorithm: "secretbox"}
+	west1Value := store.Value{Key: store.Key{KeyManager: "kms", Algorithm: "secretbox",
+		KeyID: "arn:aws:kms:us-west-1:922329555442:key/8a97cd86-54c8-4964-b9b3-4d5d6ae98139"}}
+	// A value whose key is not in the template, and a plaintext value.
+	west2Value := store.Value{Key: store.Key{KeyManager: "kms", Algorithm: "secretbox",
+		KeyID: "arn:aws:kms:us-west-2:922329555442:key/0f809ad7-ecd3-41a3-9d21-923195530c8a"}}
+	plainValue := store.Value{Key: store.Key{Algorithm: "none"}}
+	values := store.ValueList{west1Value, west2Value, plainValue}
+
+	keys, dropped := rotationKeys([]store.Key{west1}, values, "", "us-west-1", false)
+	assert.Equal(t, []store.Key{west1, west2Value.Key, plainValue.Key}, keys)
+	assert.Empty(t, dropped)
+
+	keys, _ = rotationKeys([]store.Key{west1}, values, "aesgcm256-v2", "us-west-1", false)
+	assert.Equal(t, "aesgcm256-v2", keys[1].Algorithm)
+	assert.Equal(t, "none", keys[2].Algorithm)
+
+	keys, dropped = rotationKeys([]store.Key{west1}, values, "", "us-west-1", true)
+	assert.Equal(t, []store.Key{west1}, keys)
+	assert.Equal(t, []store.Key{west2Value.Key, plainValue.Key}, dropped)
+	assert.Equal(t, "; removed kms us-west-2, none", describeDropped(dropped))
+}
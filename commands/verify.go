@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/primait/biscuit/algorithms"
+	"github.com/primait/biscuit/shared"
+	"github.com/primait/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	verifyFormatText = "text"
+	verifyFormatJSON = "json"
+
+	// unboundAlgorithmFix is the algorithm suggested for values that are not bound to their names.
+	unboundAlgorithmFix = "aesgcm256-v2"
+)
+
+type verify struct {
+	names       *[]string
+	format      *string
+	filename    *string
+	parallelism *int
+}
+
+// verifyReport is the result of verifying a set of secrets.
+type verifyReport struct {
+	Secrets  []secretVerification `json:"secrets"`
+	Failures int                  `json:"failures"`
+}
+
+// secretVerification is the result of verifying every value of one secret. Mismatch is set if the values
+// that could be decrypted do not all decrypt to the same plaintext.
+type secretVerification struct {
+	Name     string              `json:"name"`
+	OK       bool                `json:"ok"`
+	Mismatch bool                `json:"mismatch,omitempty"`
+	Values   []valueVerification `json:"values"`
+}
+
+// valueVerification is the result of decrypting one value. Unbound is set if the value is encrypted with an
+// algorithm that does not bind it to the name of the secret, which is reported but does not fail verification.
+type valueVerification struct {
+	KeyManager string `json:"key_manager,omitempty"`
+	KeyID      string `json:"key_id,omitempty"`
+	Algorithm  string `json:"algorithm"`
+	Unbound    bool   `json:"unbound,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// NewVerify configures the command to check that every value of every secret can be decrypted.
+func NewVerify(c *kingpin.CmdClause) shared.Command {
+	return &verify{
+		names: c.Arg("name", "Names of the secrets to verify. If not set, every secret is verified.").Strings(),
+		format: c.Flag("format", "Report format. Options: "+verifyFormatText+", "+verifyFormatJSON).
+			Default(verifyFormatText).
+			Enum(verifyFormatText, verifyFormatJSON),
+		filename:    shared.FilenameFlag(c),
+		parallelism: shared.ParallelismFlag(c),
+	}
+}
+
+// Run the command.
+func (r *verify) Run(ctx context.Context) error {
+	database, err := store.Open(*r.filename)
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil {
+		return err
+	}
+	names := *r.names
+	if len(names) == 0 {
+		names = entries.SecretNames()
+	}
+	for _, name := range names {
+		if _, present := entries[name]; !present || name == store.KeyTemplateName {
+			return fmt.Errorf("%s: %s", name, store.ErrNameNotFound)
+		}
+	}
+
+	var mu sync.Mutex
+	results := make(map[string]secretVerification)
+	forEachParallel(names, *r.parallelism, func(name string) {
+		result := verifySecret(ctx, name, entries[name])
+		mu.Lock()
+		defer mu.Unlock()
+		results[name] = result
+	})
+	var report verifyReport
+	for _, name := range names {
+		report.Secrets = append(report.Secrets, results[name])
+		if !results[name].OK {
+			report.Failures++
+		}
+	}
+
+	if err := writeVerifyReport(os.Stdout, *r.format, report); err != nil {
+		return err
+	}
+	if report.Failures > 0 {
+		return fmt.Errorf("%d of %d secrets failed verification", report.Failures, len(names))
+	}
+	return nil
+}
+
+// verifySecret decrypts each of values and checks that they all decrypt to the same plaintext.
+func verifySecret(ctx context.Context, name string, values store.ValueList) secretVerification {
+	result := secretVerification{Name: name, OK: len(values) > 0}
+	var first []byte
+	decrypted := false
+	for _, value := range values {
+		check := valueVerification{KeyManager: value.KeyManager, KeyID: value.KeyID,
+			Algorithm: value.Algorithm}
+		if algo, err := algorithms.New(value.Algorithm); err == nil {
+			check.Unbound = algo.NeedsKey() && !algo.BindsAdditionalData()
+		}
+		plaintext, err := decryptOneValue(ctx, value, name)
+		if err != nil {
+			check.Error = err.Error()
+			result.OK = false
+		} else if !decrypted {
+			first = plaintext
+			decrypted = true
+		} else if !bytes.Equal(first, plaintext) {
+			result.Mismatch = true
+			result.OK = false
+		}
+		result.Values = append(result.Values, check)
+	}
+	return result
+}
+
+func writeVerifyReport(w io.Writer, format string, report verifyReport) error {
+	if format == verifyFormatJSON {
+		if report.Secrets == nil {
+			report.Secrets = []secretVerification{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	for _, secret := range report.Secrets {
+		status := "ok"
+		if !secret.OK {
+			status = "FAILED"
+		}
+		noun := "values"
+		if len(secret.Values) == 1 {
+			noun = "value"
+		}
+		_, err := fmt.Fprintf(w, "%s: %s (%d %s)\n", secret.Name, status, len(secret.Values), noun)
+		if err != nil {
+			return err
+		}
+		for _, value := range secret.Values {
+			location := keyLocation(store.Key{KeyID: value.KeyID, KeyManager: value.KeyManager,
+				Algorithm: value.Algorithm})
+			if len(value.Error) > 0 {
+				if _, err := fmt.Fprintf(w, "  %s: %s\n", location, value.Error); err != nil {
+					return err
+				}
+			}
+			if value.Unbound {
+				_, err := fmt.Fprintf(w, "  %s: %s does not bind the value to its name; rotate --algorithm %s "+
+					"to bind it\n", location, value.Algorithm, unboundAlgorithmFix)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if secret.Mismatch {
+			if _, err := fmt.Fprintf(w, "  values decrypt to different plaintexts\n"); err != nil {
+				return err
+			}
+		}
+		if len(secret.Values) == 0 {
+			if _, err := fmt.Fprintf(w, "  no values\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySecret(t *testing.T) {
+	god := store.Value{Key: store.Key{Algorithm: "none"}, Ciphertext: "Z29k"}
+	devil := store.Value{Key: store.Key{Algorithm: "none"}, Ciphertext: "ZGV2aWw="}
+	corrupt := store.Value{Key: store.Key{Algorithm: "none"}, Ciphertext: "!"}
+
+	result := verifySecret(context.Background(), "password", store.ValueList{god, god})
+	assert.True(t, result.OK)
+	assert.Len(t, result.Values, 2)
+
+	result = verifySecret(context.Background(), "password", store.ValueList{god, devil})
+	assert.False(t, result.OK)
+	assert.True(t, result.Mismatch)
+
+	result = verifySecret(context.Background(), "password", store.ValueList{god, corrupt})
+	assert.False(t, result.OK)
+	assert.False(t, result.Mismatch)
+	assert.Empty(t, result.Values[0].Error)
+	assert.NotEmpty(t, result.Values[1].Error)
+
+	assert.False(t, verifySecret(context.Background(), "password", nil).OK)
+
+	unbound := store.Value{Key: store.Key{KeyManager: "testing", KeyID: "testing", Algorithm: "secretbox"}}
+	result = verifySecret(context.Background(), "password", store.ValueList{god, unbound})
+	assert.False(t, result.Values[0].Unbound)
+	assert.True(t, result.Values[1].Unbound)
+}
+
+func TestWriteVerifyReport(t *testing.T) {
+	report := verifyReport{Failures: 1, Secrets: []secretVerification{
+		{Name: "password", OK: true, Values: []valueVerification{{Algorithm: "none"}}},
+		{Name: "spice", OK: true, Values: []valueVerification{
+			{KeyManager: "testing", KeyID: "testing", Algorithm: "secretbox", Unbound: true},
+		}},
+		{Name: "username", Mismatch: true, Values: []valueVerification{
+			{Algorithm: "none"},
+			{Algorithm: "none", Error: "illegal base64 data at input byte 0"},
+		}},
+	}}
+	var output bytes.Buffer
+	assert.NoError(t, writeVerifyReport(&output, verifyFormatText, report))
+	assert.Equal(t, "password: ok (1 value)\nspice: ok (1 value)\n"+
+		"  testing testing: secretbox does not bind the value to its name; rotate --algorithm aesgcm256-v2 "+
+		"to bind it\nusername: FAILED (2 values)\n"+
+		"  none: illegal base64 data at input byte 0\n  values decrypt to different plaintexts\n",
+		output.String())
+
+	output.Reset()
+	assert.NoError(t, writeVerifyReport(&output, verifyFormatJSON, verifyReport{}))
+	assert.Equal(t, "{\n  \"secrets\": [],\n  \"failures\": 0\n}\n", output.String())
+}
@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"sync"
+)
+
+// forEachParallel calls fn once for each name, running at most parallelism calls at a time.
+func forEachParallel(names []string, parallelism int, fn func(name string)) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				fn(name)
+			}
+		}()
+	}
+	for _, name := range names {
+		work <- name
+	}
+	close(work)
+	wg.Wait()
+}
@@ -0,0 +1,128 @@
+package fakeaws
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// stack is a CloudFormation stack. Every stack is assumed to be biscuit's key template: creating one creates
+// a KMS key and reports its ARN in the KeyArn output.
+type stack struct {
+	name, id string
+	created  time.Time
+	params   map[string]string
+	keyID    string
+}
+
+type stackParameter struct {
+	ParameterKey   string
+	ParameterValue string
+}
+
+type stackOutput struct {
+	OutputKey   string
+	OutputValue string
+}
+
+type stackDescription struct {
+	StackName    string
+	StackID      string `xml:"StackId"`
+	StackStatus  string
+	CreationTime string
+	Parameters   []stackParameter `xml:"Parameters>member"`
+	Outputs      []stackOutput    `xml:"Outputs>member"`
+}
+
+type createStackResult struct {
+	XMLName struct{} `xml:"CreateStackResult"`
+	StackID string   `xml:"StackId"`
+}
+
+type describeStacksResult struct {
+	XMLName struct{}           `xml:"DescribeStacksResult"`
+	Stacks  []stackDescription `xml:"Stacks>member"`
+}
+
+type deleteStackResult struct {
+	XMLName struct{} `xml:"DeleteStackResult"`
+}
+
+func (s *Server) cloudformationActions() map[string]queryAction {
+	return map[string]queryAction{
+		"CreateStack":    s.createStack,
+		"DescribeStacks": s.describeStacks,
+		"DeleteStack":    s.deleteStack,
+	}
+}
+
+func (s *Server) findStack(r *region, nameOrID string) (*stack, error) {
+	for _, st := range r.stacks {
+		if st.name == nameOrID || st.id == nameOrID {
+			return st, nil
+		}
+	}
+	return nil, newAPIError("ValidationError", "Stack with id %s does not exist", nameOrID)
+}
+
+func (s *Server) createStack(r *region, params url.Values) (interface{}, error) {
+	name := params.Get("StackName")
+	if len(name) == 0 {
+		return nil, newAPIError("ValidationError", "StackName is required")
+	}
+	if _, present := r.stacks[name]; present {
+		return nil, newAPIError("AlreadyExistsException", "Stack [%s] already exists", name)
+	}
+	st := &stack{
+		name:    name,
+		id:      fmt.Sprintf("arn:aws:cloudformation:%s:%s:stack/%s/%s", r.name, s.accountID, name, newID()),
+		created: time.Now(),
+		params:  make(map[string]string),
+	}
+	for i := 1; ; i++ {
+		prefix := fmt.Sprintf("Parameters.member.%d.", i)
+		if _, present := params[prefix+"ParameterKey"]; !present {
+			break
+		}
+		st.params[params.Get(prefix+"ParameterKey")] = params.Get(prefix + "ParameterValue")
+	}
+	st.keyID = s.createKey(r, st.params["KeyDescription"]).id
+	r.stacks[name] = st
+	return &createStackResult{StackID: st.id}, nil
+}
+
+func (s *Server) describeStacks(r *region, params url.Values) (interface{}, error) {
+	st, err := s.findStack(r, params.Get("StackName"))
+	if err != nil {
+		return nil, err
+	}
+	description := stackDescription{
+		StackName:    st.name,
+		StackID:      st.id,
+		StackStatus:  "CREATE_COMPLETE",
+		CreationTime: st.created.UTC().Format(time.RFC3339),
+		Outputs:      []stackOutput{{OutputKey: "KeyArn", OutputValue: r.keys[st.keyID].arn}},
+	}
+	var keys []string
+	for key := range st.params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		description.Parameters = append(description.Parameters, stackParameter{key, st.params[key]})
+	}
+	return &describeStacksResult{Stacks: []stackDescription{description}}, nil
+}
+
+// deleteStack deletes the stack and schedules deletion of its key.
+func (s *Server) deleteStack(r *region, params url.Values) (interface{}, error) {
+	st, err := s.findStack(r, params.Get("StackName"))
+	if err != nil {
+		// DeleteStack succeeds for stacks that do not exist.
+		return &deleteStackResult{}, nil
+	}
+	r.keys[st.keyID].state = "PendingDeletion"
+	delete(r.stacks, st.name)
+	return &deleteStackResult{}, nil
+}
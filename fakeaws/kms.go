@@ -0,0 +1,414 @@
+package fakeaws
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	kmsTargetPrefix   = "TrentService."
+	ciphertextVersion = 1
+)
+
+type key struct {
+	id, arn, description, policy, state string
+	created                             time.Time
+	material                            []byte
+	grants                              []*grant
+}
+
+type grant struct {
+	GrantID           string            `json:"GrantId"`
+	KeyID             string            `json:"KeyId"`
+	Name              string            `json:",omitempty"`
+	CreationDate      float64           `json:",omitempty"`
+	GranteePrincipal  string            `json:",omitempty"`
+	RetiringPrincipal string            `json:",omitempty"`
+	IssuingAccount    string            `json:",omitempty"`
+	Operations        []string          `json:",omitempty"`
+	Constraints       *grantConstraints `json:",omitempty"`
+	token             string
+}
+
+type grantConstraints struct {
+	EncryptionContextEquals map[string]string `json:",omitempty"`
+	EncryptionContextSubset map[string]string `json:",omitempty"`
+}
+
+type kmsRequest struct {
+	KeyID             string            `json:"KeyId"`
+	AliasName         string            `json:",omitempty"`
+	TargetKeyID       string            `json:"TargetKeyId"`
+	EncryptionContext map[string]string `json:",omitempty"`
+	NumberOfBytes     int               `json:",omitempty"`
+	KeySpec           string            `json:",omitempty"`
+	CiphertextBlob    []byte            `json:",omitempty"`
+	PolicyName        string            `json:",omitempty"`
+	Policy            string            `json:",omitempty"`
+	Name              string            `json:",omitempty"`
+	GranteePrincipal  string            `json:",omitempty"`
+	RetiringPrincipal string            `json:",omitempty"`
+	Operations        []string          `json:",omitempty"`
+	Constraints       *grantConstraints `json:",omitempty"`
+	GrantID           string            `json:"GrantId"`
+	GrantToken        string            `json:",omitempty"`
+}
+
+type kmsAction func(r *region, req *kmsRequest) (interface{}, error)
+
+func (s *Server) kmsActions() map[string]kmsAction {
+	return map[string]kmsAction{
+		"GenerateDataKey": s.generateDataKey,
+		"Decrypt":         s.decrypt,
+		"CreateAlias":     s.createAlias,
+		"DeleteAlias":     s.deleteAlias,
+		"ListAliases":     s.listAliases,
+		"DescribeKey":     s.describeKey,
+		"GetKeyPolicy":    s.getKeyPolicy,
+		"PutKeyPolicy":    s.putKeyPolicy,
+		"CreateGrant":     s.createGrant,
+		"ListGrants":      s.listGrants,
+		"RetireGrant":     s.retireGrant,
+		"RevokeGrant":     s.revokeGrant,
+	}
+}
+
+func (s *Server) serveKms(w http.ResponseWriter, target string, r *region, body []byte) {
+	var output interface{}
+	name := strings.TrimPrefix(target, kmsTargetPrefix)
+	s.kmsCalls[name]++
+	action, present := s.kmsActions()[name]
+	err := error(newAPIError("UnknownOperationException", "%s is not supported", target))
+	if present {
+		var req kmsRequest
+		if err = json.Unmarshal(body, &req); err != nil {
+			err = newAPIError("SerializationException", "%s", err)
+		} else {
+			output, err = action(r, &req)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		code := "KMSInternalException"
+		if apiErr, ok := err.(*apiError); ok {
+			code = apiErr.code
+		}
+		json.NewEncoder(w).Encode(map[string]string{"__type": code, "message": err.Error()})
+		return
+	}
+	if output == nil {
+		output = struct{}{}
+	}
+	json.NewEncoder(w).Encode(output)
+}
+
+// createKey creates a new key. The caller must hold s.mu.
+func (s *Server) createKey(r *region, description string) *key {
+	k := &key{
+		id:          newID(),
+		description: description,
+		policy:      `{"Version":"2012-10-17","Statement":[]}`,
+		state:       "Enabled",
+		created:     time.Now(),
+		material:    make([]byte, 32),
+	}
+	k.arn = "arn:aws:kms:" + r.name + ":" + s.accountID + ":key/" + k.id
+	if _, err := rand.Read(k.material); err != nil {
+		panic(err)
+	}
+	r.keys[k.id] = k
+	return k
+}
+
+// findKey resolves a key ID, key ARN, alias name or alias ARN to a key in the region.
+func (s *Server) findKey(r *region, keyID string) (*key, error) {
+	resource := keyID
+	if strings.HasPrefix(keyID, "arn:") {
+		prefix := "arn:aws:kms:" + r.name + ":" + s.accountID + ":"
+		if !strings.HasPrefix(keyID, prefix) {
+			return nil, newAPIError("NotFoundException", "Invalid arn %s", keyID)
+		}
+		resource = strings.TrimPrefix(keyID, prefix)
+	}
+	if strings.HasPrefix(resource, "alias/") {
+		if id, present := r.aliases[resource]; present {
+			return r.keys[id], nil
+		}
+		return nil, newAPIError("NotFoundException", "Alias arn:aws:kms:%s:%s:%s is not found.", r.name,
+			s.accountID, resource)
+	}
+	if k, present := r.keys[strings.TrimPrefix(resource, "key/")]; present {
+		return k, nil
+	}
+	return nil, newAPIError("NotFoundException", "Key 'arn:aws:kms:%s:%s:key/%s' does not exist", r.name,
+		s.accountID, strings.TrimPrefix(resource, "key/"))
+}
+
+func (s *Server) findEnabledKey(r *region, keyID string) (*key, error) {
+	k, err := s.findKey(r, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if k.state != "Enabled" {
+		return nil, newAPIError("DisabledException", "%s is %s.", k.arn, k.state)
+	}
+	return k, nil
+}
+
+func encryptionContextBytes(context map[string]string) []byte {
+	if len(context) == 0 {
+		return nil
+	}
+	// json.Marshal sorts map keys, so this is canonical.
+	b, err := json.Marshal(context)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (k *key) aead() cipher.AEAD {
+	block, err := aes.NewCipher(k.material)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// The ciphertext blob is: version, length of key ID, key ID, nonce, sealed plaintext.
+func (s *Server) generateDataKey(r *region, req *kmsRequest) (interface{}, error) {
+	k, err := s.findEnabledKey(r, req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	size := req.NumberOfBytes
+	switch {
+	case req.KeySpec == "AES_128":
+		size = 16
+	case req.KeySpec == "AES_256":
+		size = 32
+	case size <= 0 || size > 1024:
+		return nil, newAPIError("ValidationException", "NumberOfBytes or KeySpec is required.")
+	}
+	plaintext := make([]byte, size)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, err
+	}
+	aead := k.aead()
+	blob := append([]byte{ciphertextVersion, byte(len(k.id))}, k.id...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	blob = append(blob, nonce...)
+	blob = aead.Seal(blob, nonce, plaintext, encryptionContextBytes(req.EncryptionContext))
+	return map[string]interface{}{
+		"KeyId":          k.arn,
+		"Plaintext":      plaintext,
+		"CiphertextBlob": blob,
+	}, nil
+}
+
+func (s *Server) decrypt(r *region, req *kmsRequest) (interface{}, error) {
+	invalid := newAPIError("InvalidCiphertextException", "")
+	blob := req.CiphertextBlob
+	if len(blob) < 2 || blob[0] != ciphertextVersion || len(blob) < 2+int(blob[1]) {
+		return nil, invalid
+	}
+	k, present := r.keys[string(blob[2:2+blob[1]])]
+	if !present {
+		return nil, invalid
+	}
+	if k.state != "Enabled" {
+		return nil, newAPIError("DisabledException", "%s is %s.", k.arn, k.state)
+	}
+	aead := k.aead()
+	sealed := blob[2+blob[1]:]
+	if len(sealed) < aead.NonceSize() {
+		return nil, invalid
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():],
+		encryptionContextBytes(req.EncryptionContext))
+	if err != nil {
+		return nil, invalid
+	}
+	return map[string]interface{}{
+		"KeyId":     k.arn,
+		"Plaintext": plaintext,
+	}, nil
+}
+
+func (s *Server) createAlias(r *region, req *kmsRequest) (interface{}, error) {
+	if !strings.HasPrefix(req.AliasName, "alias/") || strings.HasPrefix(req.AliasName, "alias/aws/") {
+		return nil, newAPIError("ValidationException", "Alias must start with the prefix \"alias/\".")
+	}
+	if _, present := r.aliases[req.AliasName]; present {
+		return nil, newAPIError("AlreadyExistsException", "An alias with the name arn:aws:kms:%s:%s:%s "+
+			"already exists", r.name, s.accountID, req.AliasName)
+	}
+	k, err := s.findKey(r, req.TargetKeyID)
+	if err != nil {
+		return nil, err
+	}
+	r.aliases[req.AliasName] = k.id
+	return nil, nil
+}
+
+func (s *Server) deleteAlias(r *region, req *kmsRequest) (interface{}, error) {
+	if _, present := r.aliases[req.AliasName]; !present {
+		return nil, newAPIError("NotFoundException", "Alias arn:aws:kms:%s:%s:%s is not found.", r.name,
+			s.accountID, req.AliasName)
+	}
+	delete(r.aliases, req.AliasName)
+	return nil, nil
+}
+
+func (s *Server) listAliases(r *region, req *kmsRequest) (interface{}, error) {
+	var filter string
+	if len(req.KeyID) > 0 {
+		k, err := s.findKey(r, req.KeyID)
+		if err != nil {
+			return nil, err
+		}
+		filter = k.id
+	}
+	var names []string
+	for name, id := range r.aliases {
+		if len(filter) == 0 || id == filter {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	aliases := []map[string]string{}
+	for _, name := range names {
+		aliases = append(aliases, map[string]string{
+			"AliasName":   name,
+			"AliasArn":    "arn:aws:kms:" + r.name + ":" + s.accountID + ":" + name,
+			"TargetKeyId": r.aliases[name],
+		})
+	}
+	return map[string]interface{}{"Aliases": aliases, "Truncated": false}, nil
+}
+
+func (s *Server) describeKey(r *region, req *kmsRequest) (interface{}, error) {
+	k, err := s.findKey(r, req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"KeyMetadata": map[string]interface{}{
+			"AWSAccountId": s.accountID,
+			"KeyId":        k.id,
+			"Arn":          k.arn,
+			"CreationDate": float64(k.created.Unix()),
+			"Enabled":      k.state == "Enabled",
+			"Description":  k.description,
+			"KeyUsage":     "ENCRYPT_DECRYPT",
+			"KeyState":     k.state,
+			"KeyManager":   "CUSTOMER",
+			"Origin":       "AWS_KMS",
+		},
+	}, nil
+}
+
+func (s *Server) getKeyPolicy(r *region, req *kmsRequest) (interface{}, error) {
+	k, err := s.findKey(r, req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if req.PolicyName != "default" {
+		return nil, newAPIError("NotFoundException", "No such policy exists")
+	}
+	return map[string]string{"Policy": k.policy}, nil
+}
+
+func (s *Server) putKeyPolicy(r *region, req *kmsRequest) (interface{}, error) {
+	k, err := s.findKey(r, req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if req.PolicyName != "default" {
+		return nil, newAPIError("ValidationException", "PolicyName must be default")
+	}
+	if !json.Valid([]byte(req.Policy)) {
+		return nil, newAPIError("MalformedPolicyDocumentException", "The policy is not valid JSON.")
+	}
+	k.policy = req.Policy
+	return nil, nil
+}
+
+func (s *Server) createGrant(r *region, req *kmsRequest) (interface{}, error) {
+	k, err := s.findKey(r, req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.GranteePrincipal) == 0 || len(req.Operations) == 0 {
+		return nil, newAPIError("ValidationException", "GranteePrincipal and Operations are required.")
+	}
+	g := &grant{
+		GrantID:           newID(),
+		KeyID:             k.arn,
+		Name:              req.Name,
+		CreationDate:      float64(time.Now().Unix()),
+		GranteePrincipal:  req.GranteePrincipal,
+		RetiringPrincipal: req.RetiringPrincipal,
+		IssuingAccount:    "arn:aws:iam::" + s.accountID + ":root",
+		Operations:        req.Operations,
+		Constraints:       req.Constraints,
+		token:             newID(),
+	}
+	k.grants = append(k.grants, g)
+	return map[string]string{"GrantId": g.GrantID, "GrantToken": g.token}, nil
+}
+
+func (s *Server) listGrants(r *region, req *kmsRequest) (interface{}, error) {
+	k, err := s.findKey(r, req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	grants := []*grant{}
+	grants = append(grants, k.grants...)
+	return map[string]interface{}{"Grants": grants, "Truncated": false}, nil
+}
+
+func (s *Server) removeGrant(k *key, matches func(g *grant) bool) error {
+	for i, g := range k.grants {
+		if matches(g) {
+			k.grants = append(k.grants[:i], k.grants[i+1:]...)
+			return nil
+		}
+	}
+	return newAPIError("NotFoundException", "Grant not found.")
+}
+
+func (s *Server) retireGrant(r *region, req *kmsRequest) (interface{}, error) {
+	if len(req.GrantToken) > 0 {
+		for _, k := range r.keys {
+			if err := s.removeGrant(k, func(g *grant) bool { return g.token == req.GrantToken }); err == nil {
+				return nil, nil
+			}
+		}
+		return nil, newAPIError("InvalidGrantTokenException", "Grant token is invalid.")
+	}
+	return s.revokeGrant(r, req)
+}
+
+func (s *Server) revokeGrant(r *region, req *kmsRequest) (interface{}, error) {
+	k, err := s.findKey(r, req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.removeGrant(k, func(g *grant) bool { return g.GrantID == req.GrantID })
+}
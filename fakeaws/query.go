@@ -0,0 +1,62 @@
+package fakeaws
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+)
+
+// queryAction implements one action of a service that uses the AWS query protocol. The result must be a
+// struct whose XMLName is the <ActionResult> element.
+type queryAction func(r *region, params url.Values) (interface{}, error)
+
+type queryResponse struct {
+	XMLName   xml.Name
+	Result    interface{}
+	RequestID string `xml:"ResponseMetadata>RequestId"`
+}
+
+type queryErrorResponse struct {
+	XMLName   xml.Name `xml:"ErrorResponse"`
+	Type      string   `xml:"Error>Type"`
+	Code      string   `xml:"Error>Code"`
+	Message   string   `xml:"Error>Message"`
+	RequestID string   `xml:"RequestId"`
+}
+
+func (s *Server) serveQuery(w http.ResponseWriter, actions map[string]queryAction, r *region, body []byte) {
+	params, err := url.ParseQuery(string(body))
+	if err != nil {
+		writeQueryError(w, newAPIError("MalformedQueryString", "%s", err))
+		return
+	}
+	name := params.Get("Action")
+	action, present := actions[name]
+	if !present {
+		writeQueryError(w, newAPIError("InvalidAction", "Could not find operation %s", name))
+		return
+	}
+	result, err := action(r, params)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	xml.NewEncoder(w).Encode(queryResponse{
+		XMLName:   xml.Name{Local: name + "Response"},
+		Result:    result,
+		RequestID: newID(),
+	})
+}
+
+func writeQueryError(w http.ResponseWriter, err error) {
+	response := queryErrorResponse{Type: "Sender", Code: "InternalFailure", Message: err.Error(),
+		RequestID: newID()}
+	if apiErr, ok := err.(*apiError); ok {
+		response.Code = apiErr.code
+		response.Message = apiErr.message
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusBadRequest)
+	xml.NewEncoder(w).Encode(response)
+}
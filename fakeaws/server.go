@@ -0,0 +1,144 @@
+// Package fakeaws is an in-memory emulation of the parts of AWS KMS, STS and CloudFormation that biscuit
+// uses. It exists so that the end-to-end tests can run without AWS credentials. Point biscuit at it by
+// setting BISCUIT_AWS_ENDPOINT to the server's URL.
+//
+// The emulation is deliberately shallow: requests are routed by the service and region in the SigV4
+// credential scope, but signatures, key policies and grants are never enforced.
+package fakeaws
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultAccountID is the account ID used by New.
+const DefaultAccountID = "111122223333"
+
+// Server is an http.Handler that emulates AWS KMS, STS and CloudFormation.
+type Server struct {
+	accountID string
+
+	mu       sync.Mutex
+	regions  map[string]*region
+	kmsCalls map[string]int // action -> number of requests
+}
+
+type region struct {
+	name    string
+	keys    map[string]*key   // key ID -> key
+	aliases map[string]string // alias name -> key ID
+	stacks  map[string]*stack // stack name -> stack
+}
+
+// apiError is an error that is reported to the client using the service's error protocol.
+type apiError struct {
+	code, message string
+}
+
+func (e *apiError) Error() string {
+	return e.code + ": " + e.message
+}
+
+func newAPIError(code, format string, args ...interface{}) *apiError {
+	return &apiError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// New returns a Server with no keys.
+func New() *Server {
+	return NewWithAccountID(DefaultAccountID)
+}
+
+// NewWithAccountID returns a Server whose resources belong to accountID.
+func NewWithAccountID(accountID string) *Server {
+	return &Server{accountID: accountID, regions: make(map[string]*region), kmsCalls: make(map[string]int)}
+}
+
+// AccountID returns the account ID that owns the server's resources.
+func (s *Server) AccountID() string {
+	return s.accountID
+}
+
+// CreateKey creates an enabled KMS key in a region and returns its ARN.
+func (s *Server) CreateKey(regionName, description string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createKey(s.region(regionName), description).arn
+}
+
+// KmsCalls returns the number of requests made for a KMS action, ex: Decrypt, in any region.
+func (s *Server) KmsCalls(action string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.kmsCalls[action]
+}
+
+// region returns the state for a region, creating it if necessary. The caller must hold s.mu.
+func (s *Server) region(name string) *region {
+	if r, present := s.regions[name]; present {
+		return r
+	}
+	r := &region{
+		name:    name,
+		keys:    make(map[string]*key),
+		aliases: make(map[string]string),
+		stacks:  make(map[string]*stack),
+	}
+	s.regions[name] = r
+	return r
+}
+
+// ServeHTTP dispatches a request to the emulated service named in its credential scope.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	regionName, service := credentialScope(r.Header.Get("Authorization"))
+	if len(regionName) == 0 {
+		http.Error(w, "missing or malformed SigV4 Authorization header", http.StatusForbidden)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch service {
+	case "kms":
+		s.serveKms(w, r.Header.Get("X-Amz-Target"), s.region(regionName), body)
+	case "sts":
+		s.serveQuery(w, s.stsActions(), s.region(regionName), body)
+	case "cloudformation":
+		s.serveQuery(w, s.cloudformationActions(), s.region(regionName), body)
+	default:
+		http.Error(w, fmt.Sprintf("unsupported service '%s'", service), http.StatusNotImplemented)
+	}
+}
+
+// credentialScope extracts the region and service from a SigV4 Authorization header, ex:
+// AWS4-HMAC-SHA256 Credential=AKID/20210101/us-west-1/kms/aws4_request, SignedHeaders=..., Signature=...
+func credentialScope(authorization string) (string, string) {
+	for _, field := range strings.Fields(strings.Replace(authorization, ",", " ", -1)) {
+		if !strings.HasPrefix(field, "Credential=") {
+			continue
+		}
+		scope := strings.Split(strings.TrimPrefix(field, "Credential="), "/")
+		if len(scope) != 5 {
+			return "", ""
+		}
+		return scope[2], scope[3]
+	}
+	return "", ""
+}
+
+// newID returns a random identifier formatted like a UUID.
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,186 @@
+package fakeaws
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSession(t *testing.T, server *Server, region string) *session.Session {
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return session.Must(session.NewSession(aws.NewConfig().
+		WithRegion(region).
+		WithEndpoint(ts.URL).
+		WithCredentials(credentials.NewStaticCredentials("AKIDFAKE", "secret", ""))))
+}
+
+func errorCode(err error) string {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code()
+	}
+	return ""
+}
+
+func TestKms_GenerateDataKeyAndDecrypt(t *testing.T) {
+	server := New()
+	arn := server.CreateKey("us-west-1", "test")
+	client := kms.New(newSession(t, server, "us-west-1"))
+	context := aws.StringMap(map[string]string{"SecretName": "password"})
+
+	generated, err := client.GenerateDataKey(&kms.GenerateDataKeyInput{
+		KeyId:             aws.String(arn),
+		EncryptionContext: context,
+		NumberOfBytes:     aws.Int64(32),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, arn, *generated.KeyId)
+	assert.Len(t, generated.Plaintext, 32)
+
+	decrypted, err := client.Decrypt(&kms.DecryptInput{
+		CiphertextBlob:    generated.CiphertextBlob,
+		EncryptionContext: context,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, generated.Plaintext, decrypted.Plaintext)
+
+	_, err = client.Decrypt(&kms.DecryptInput{
+		CiphertextBlob:    generated.CiphertextBlob,
+		EncryptionContext: aws.StringMap(map[string]string{"SecretName": "username"}),
+	})
+	assert.Equal(t, kms.ErrCodeInvalidCiphertextException, errorCode(err))
+
+	_, err = kms.New(newSession(t, server, "us-west-2")).Decrypt(&kms.DecryptInput{
+		CiphertextBlob:    generated.CiphertextBlob,
+		EncryptionContext: context,
+	})
+	assert.Equal(t, kms.ErrCodeInvalidCiphertextException, errorCode(err))
+
+	_, err = client.GenerateDataKey(&kms.GenerateDataKeyInput{
+		KeyId:         aws.String("c06320d9-aaaa-aaaa-aaaa-08263b0789d5"),
+		NumberOfBytes: aws.Int64(32),
+	})
+	assert.Equal(t, kms.ErrCodeNotFoundException, errorCode(err))
+}
+
+func TestKms_Aliases(t *testing.T) {
+	server := New()
+	arn := server.CreateKey("us-east-1", "test")
+	client := kms.New(newSession(t, server, "us-east-1"))
+
+	_, err := client.CreateAlias(&kms.CreateAliasInput{
+		AliasName:   aws.String("alias/biscuit-default"),
+		TargetKeyId: aws.String(arn),
+	})
+	require.NoError(t, err)
+	_, err = client.CreateAlias(&kms.CreateAliasInput{
+		AliasName:   aws.String("alias/biscuit-default"),
+		TargetKeyId: aws.String(arn),
+	})
+	assert.Equal(t, kms.ErrCodeAlreadyExistsException, errorCode(err))
+
+	aliases, err := client.ListAliases(nil)
+	require.NoError(t, err)
+	require.Len(t, aliases.Aliases, 1)
+	assert.Equal(t, "arn:aws:kms:us-east-1:"+DefaultAccountID+":alias/biscuit-default",
+		*aliases.Aliases[0].AliasArn)
+
+	described, err := client.DescribeKey(&kms.DescribeKeyInput{KeyId: aliases.Aliases[0].AliasArn})
+	require.NoError(t, err)
+	assert.Equal(t, arn, *described.KeyMetadata.Arn)
+	assert.True(t, *described.KeyMetadata.Enabled)
+
+	_, err = client.PutKeyPolicy(&kms.PutKeyPolicyInput{
+		KeyId:      aws.String("alias/biscuit-default"),
+		PolicyName: aws.String("default"),
+		Policy:     aws.String(`{"Statement":[]}`),
+	})
+	require.NoError(t, err)
+	policy, err := client.GetKeyPolicy(&kms.GetKeyPolicyInput{
+		KeyId:      described.KeyMetadata.KeyId,
+		PolicyName: aws.String("default"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{"Statement":[]}`, *policy.Policy)
+}
+
+func TestKms_Grants(t *testing.T) {
+	server := New()
+	arn := server.CreateKey("us-east-1", "test")
+	client := kms.New(newSession(t, server, "us-east-1"))
+
+	created, err := client.CreateGrant(&kms.CreateGrantInput{
+		KeyId:            aws.String(arn),
+		Name:             aws.String("biscuit-abc"),
+		GranteePrincipal: aws.String("arn:aws:iam::111122223333:role/webserver"),
+		Operations:       aws.StringSlice([]string{"Decrypt"}),
+		Constraints: &kms.GrantConstraints{
+			EncryptionContextEquals: aws.StringMap(map[string]string{"SecretName": "password"}),
+		},
+	})
+	require.NoError(t, err)
+
+	grants, err := client.ListGrants(&kms.ListGrantsInput{KeyId: aws.String(arn)})
+	require.NoError(t, err)
+	require.Len(t, grants.Grants, 1)
+	assert.Equal(t, *created.GrantId, *grants.Grants[0].GrantId)
+	assert.Equal(t, "biscuit-abc", *grants.Grants[0].Name)
+	assert.Equal(t, "password", *grants.Grants[0].Constraints.EncryptionContextEquals["SecretName"])
+
+	_, err = client.RetireGrant(&kms.RetireGrantInput{GrantToken: created.GrantToken})
+	require.NoError(t, err)
+	_, err = client.RevokeGrant(&kms.RevokeGrantInput{KeyId: aws.String(arn), GrantId: created.GrantId})
+	assert.Equal(t, kms.ErrCodeNotFoundException, errorCode(err))
+}
+
+func TestSts_GetCallerIdentity(t *testing.T) {
+	server := New()
+	identity, err := sts.New(newSession(t, server, "us-east-1")).GetCallerIdentity(nil)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultAccountID, *identity.Account)
+	assert.Equal(t, server.CallerArn(), *identity.Arn)
+}
+
+func TestCloudFormation_Stacks(t *testing.T) {
+	server := New()
+	client := cloudformation.New(newSession(t, server, "us-west-2"))
+
+	_, err := client.DescribeStacks(&cloudformation.DescribeStacksInput{StackName: aws.String("biscuit-default")})
+	assert.Equal(t, "ValidationError", errorCode(err))
+
+	created, err := client.CreateStack(&cloudformation.CreateStackInput{
+		StackName:    aws.String("biscuit-default"),
+		TemplateBody: aws.String("{}"),
+		Parameters: []*cloudformation.Parameter{
+			{ParameterKey: aws.String("KeyDescription"), ParameterValue: aws.String("test")},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, client.WaitUntilStackCreateComplete(
+		&cloudformation.DescribeStacksInput{StackName: created.StackId}))
+
+	described, err := client.DescribeStacks(&cloudformation.DescribeStacksInput{StackName: created.StackId})
+	require.NoError(t, err)
+	require.Len(t, described.Stacks, 1)
+	require.Len(t, described.Stacks[0].Outputs, 1)
+	assert.Equal(t, "KeyArn", *described.Stacks[0].Outputs[0].OutputKey)
+
+	key, err := kms.New(newSession(t, server, "us-west-2")).DescribeKey(&kms.DescribeKeyInput{
+		KeyId: described.Stacks[0].Outputs[0].OutputValue})
+	require.NoError(t, err)
+	assert.Equal(t, "test", *key.KeyMetadata.Description)
+
+	_, err = client.DeleteStack(&cloudformation.DeleteStackInput{StackName: aws.String("biscuit-default")})
+	require.NoError(t, err)
+	require.NoError(t, client.WaitUntilStackDeleteComplete(
+		&cloudformation.DescribeStacksInput{StackName: aws.String("biscuit-default")}))
+}
@@ -0,0 +1,32 @@
+package fakeaws
+
+import (
+	"net/url"
+)
+
+type getCallerIdentityResult struct {
+	XMLName struct{} `xml:"GetCallerIdentityResult"`
+	Account string
+	Arn     string
+	UserID  string `xml:"UserId"`
+}
+
+func (s *Server) stsActions() map[string]queryAction {
+	return map[string]queryAction{
+		"GetCallerIdentity": s.getCallerIdentity,
+	}
+}
+
+// CallerArn returns the ARN of the identity that every request is attributed to.
+func (s *Server) CallerArn() string {
+	return "arn:aws:iam::" + s.accountID + ":user/biscuit"
+}
+
+// noinspection GoUnusedParameter
+func (s *Server) getCallerIdentity(r *region, params url.Values) (interface{}, error) {
+	return &getCallerIdentityResult{
+		Account: s.accountID,
+		Arn:     s.CallerArn(),
+		UserID:  "AIDAFAKEBISCUITUSER",
+	}, nil
+}
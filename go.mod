@@ -3,6 +3,7 @@ module github.com/primait/biscuit
 go 1.15
 
 require (
+	filippo.io/age v1.0.0
 	github.com/agtorre/gocolorize v1.0.1-0.20170217021338-99fea4bc9517
 	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751
 	github.com/alecthomas/units v0.0.0-20210208195552-ff826a37aa15
@@ -15,10 +16,11 @@ require (
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2
 	github.com/robfig/glock v0.0.0-20200903114536-58065e542405
 	github.com/stretchr/testify v1.6.2-0.20200818115829-54d05a4e1844
-	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
+	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
 	golang.org/x/lint v0.0.0-20200302205851-738671d3881b
 	golang.org/x/mod v0.3.1-0.20200828183125-ce943fd02449
-	golang.org/x/sys v0.0.0-20210217105451-b926d437f341
+	golang.org/x/sys v0.0.0-20210903071746-97244b99971b
+	golang.org/x/term v0.0.0-20210615171337-6886f2dfbf5b
 	golang.org/x/tools v0.0.0-20201013201025-64a9e34f3752
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
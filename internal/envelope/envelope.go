@@ -0,0 +1,137 @@
+// Package envelope encrypts and decrypts the values of secrets: each value is encrypted under a data key,
+// and the data key under a key manager's key. It is shared by the biscuit commands and the client package.
+package envelope
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/primait/biscuit/algorithms"
+	"github.com/primait/biscuit/internal/keycache"
+	"github.com/primait/biscuit/keymanager"
+	"github.com/primait/biscuit/store"
+)
+
+// KeyManagers returns the key manager for a label. keymanager.New returns the registered key managers.
+type KeyManagers func(label string) (keymanager.KeyManager, error)
+
+// DataKeyDecrypter returns the plaintext data key of a value of the secret name.
+type DataKeyDecrypter func(ctx context.Context, value store.Value, name string) ([]byte, error)
+
+// Encrypt encrypts the plaintext of the secret name under key.
+func Encrypt(ctx context.Context, managers KeyManagers, key store.Key, name string, plaintext []byte) (store.Value,
+	error) {
+	var value store.Value
+	algo, err := algorithms.New(key.Algorithm)
+	if err != nil {
+		return value, err
+	}
+	value.Algorithm = algo.Label()
+
+	var envelopeKey keymanager.EnvelopeKey
+	if algo.NeedsKey() {
+		keyManager, err := managers(key.KeyManager)
+		if err != nil {
+			return value, err
+		}
+		value.KeyManager = keyManager.Label()
+		envelopeKey, err = keyManager.GenerateEnvelopeKey(ctx, key.KeyID, name)
+		if err != nil {
+			return value, err
+		}
+		value.KeyID = envelopeKey.ResolvedID
+		value.KeyCiphertext = base64.StdEncoding.EncodeToString(envelopeKey.Ciphertext)
+	}
+
+	ciphertext, err := algo.Encrypt(envelopeKey.Plaintext, plaintext, additionalData(name, value.KeyID))
+	if err != nil {
+		return value, err
+	}
+	value.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext)
+	return value, nil
+}
+
+// EncryptAll encrypts plaintext under each of the keys in parallel. The values are returned in the order of
+// the keys.
+func EncryptAll(ctx context.Context, managers KeyManagers, keys []store.Key, name string,
+	plaintext []byte) (store.ValueList, error) {
+	values := make(store.ValueList, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key store.Key) {
+			defer wg.Done()
+			values[i], errs[i] = Encrypt(ctx, managers, key, name, plaintext)
+		}(i, key)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// Decrypt decrypts one value of the secret name, using decryptKey to decrypt its data key.
+func Decrypt(ctx context.Context, value store.Value, name string, decryptKey DataKeyDecrypter) ([]byte, error) {
+	algo, err := algorithms.New(value.Algorithm)
+	if err != nil {
+		return []byte{}, err
+	}
+	var keyPlaintext []byte
+	if algo.NeedsKey() {
+		keyPlaintext, err = decryptKey(ctx, value, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	decoded, err := value.GetCiphertext()
+	if err != nil {
+		return []byte{}, err
+	}
+	plaintext, err := algo.Decrypt(keyPlaintext, decoded, additionalData(name, value.KeyID))
+	return plaintext, err
+}
+
+// DataKeys returns a DataKeyDecrypter that asks the key manager of each value to decrypt its data key.
+func DataKeys(managers KeyManagers) DataKeyDecrypter {
+	return func(ctx context.Context, value store.Value, name string) ([]byte, error) {
+		keyManager, err := managers(value.KeyManager)
+		if err != nil {
+			return []byte{}, err
+		}
+		keyCiphertext, err := value.GetKeyCiphertext()
+		if err != nil {
+			return []byte{}, err
+		}
+		keyPlaintext, err := keyManager.Decrypt(ctx, value.Key.KeyID, keyCiphertext, name)
+		if err != nil {
+			return []byte{}, err
+		}
+		return keyPlaintext, nil
+	}
+}
+
+// CachedDataKeys returns a DataKeyDecrypter that keeps the data keys returned by decryptKey in cache.
+func CachedDataKeys(cache *keycache.Cache, decryptKey DataKeyDecrypter) DataKeyDecrypter {
+	return func(ctx context.Context, value store.Value, name string) ([]byte, error) {
+		// The name is part of the id because key managers may bind the data key to it.
+		id := strings.Join([]string{value.KeyManager, value.KeyID, value.KeyCiphertext, name}, "\x00")
+		return cache.Get(ctx, id, func() ([]byte, error) {
+			return decryptKey(ctx, value, name)
+		})
+	}
+}
+
+// additionalData returns the data that algorithms supporting it authenticate along with the ciphertext. This
+// binds the ciphertext to the name of the secret and the key it is encrypted under, so that it cannot be
+// moved to another name undetected even when the key manager does not bind the name itself.
+func additionalData(name, keyID string) []byte {
+	return []byte(fmt.Sprintf("%d:%s,%d:%s,", len(name), name, len(keyID), keyID))
+}
@@ -0,0 +1,65 @@
+package envelope
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/primait/biscuit/internal/keycache"
+	"github.com/primait/biscuit/keymanager"
+	"github.com/primait/biscuit/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptAll(t *testing.T) {
+	ctx := context.Background()
+	keys := []store.Key{
+		{KeyManager: "testing", KeyID: "first", Algorithm: "aesgcm256-v2"},
+		{Algorithm: "none"},
+		{KeyManager: "testing", KeyID: "second", Algorithm: "secretbox"},
+	}
+	values, err := EncryptAll(ctx, keymanager.New, keys, "password", []byte("god"))
+	require.NoError(t, err)
+	require.Len(t, values, 3)
+	assert.Equal(t, []string{"aesgcm256-v2", "none", "secretbox"},
+		[]string{values[0].Algorithm, values[1].Algorithm, values[2].Algorithm}, "values should follow the keys")
+
+	for _, value := range values {
+		plaintext, err := Decrypt(ctx, value, "password", DataKeys(keymanager.New))
+		require.NoError(t, err)
+		assert.Equal(t, "god", string(plaintext))
+	}
+	_, err = Decrypt(ctx, values[0], "username", DataKeys(keymanager.New))
+	assert.Error(t, err, "the value should be bound to its name")
+
+	_, err = EncryptAll(ctx, keymanager.New, append(keys, store.Key{KeyManager: "unknown", Algorithm: "secretbox"}),
+		"password", []byte("god"))
+	assert.Error(t, err)
+}
+
+func TestCachedDataKeys(t *testing.T) {
+	ctx := context.Background()
+	value, err := Encrypt(ctx, keymanager.New, store.Key{KeyManager: "testing", KeyID: "key",
+		Algorithm: "aesgcm256-v2"}, "password", []byte("god"))
+	require.NoError(t, err)
+
+	calls := 0
+	decryptKey := func(ctx context.Context, value store.Value, name string) ([]byte, error) {
+		calls++
+		if calls > 1 {
+			return nil, errors.New("the key should have been cached")
+		}
+		return DataKeys(keymanager.New)(ctx, value, name)
+	}
+	cache := keycache.New(time.Hour, 0)
+	defer cache.Close()
+	cached := CachedDataKeys(cache, decryptKey)
+	for i := 0; i < 2; i++ {
+		plaintext, err := Decrypt(ctx, value, "password", cached)
+		require.NoError(t, err)
+		assert.Equal(t, "god", string(plaintext))
+	}
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,201 @@
+// Package keycache holds data keys in memory, so that biscuit, the agent and the client package can use the
+// same data key many times with a single call to the key manager.
+package keycache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Key is a data key held by the cache. Only the plaintext is locked in memory and wiped.
+type Key struct {
+	// ResolvedID is the fully qualified ID of the key manager's key.
+	ResolvedID string
+	// Plaintext is the plaintext data key.
+	Plaintext []byte
+	// Ciphertext is the data key encrypted by the key manager.
+	Ciphertext []byte
+}
+
+// Cache holds data keys until they are older than a maximum age or have been used a maximum number of
+// times. Keys are kept in memory that is locked against swapping where the platform allows it, and are
+// overwritten with zeros when they are discarded. It is safe for concurrent use.
+type Cache struct {
+	maxAge  time.Duration
+	maxUses int
+	now     func() time.Time
+
+	mu         sync.Mutex
+	keys       map[string]*cachedKey
+	closed     bool
+	lockWarned bool
+}
+
+type cachedKey struct {
+	key     Key
+	locked  bool
+	fetched bool
+	ready   chan struct{}
+	created time.Time
+	uses    int
+}
+
+// New returns a Cache that uses each key for at most maxAge and at most maxUses times. A limit of zero means
+// that keys are not limited in that way.
+func New(maxAge time.Duration, maxUses int) *Cache {
+	return &Cache{maxAge: maxAge, maxUses: maxUses, now: time.Now, keys: make(map[string]*cachedKey)}
+}
+
+// Get returns a copy of the plaintext of the key identified by id, calling decrypt and caching the result if
+// it is not cached or has reached a limit. id must identify everything the key manager checks when
+// decrypting, such as the key ciphertext and the name of the secret.
+func (c *Cache) Get(ctx context.Context, id string, decrypt func() ([]byte, error)) ([]byte, error) {
+	key, err := c.GetKey(ctx, id, func() (Key, error) {
+		plaintext, err := decrypt()
+		return Key{Plaintext: plaintext}, err
+	})
+	return key.Plaintext, err
+}
+
+// GetKey returns a copy of the key identified by id, calling fetch and caching the result if it is not
+// cached or has reached a limit. Concurrent callers wait for a single fetch of the same id until their own
+// ctx is done. Errors are not cached: if the fetch fails, for example because its caller was canceled, the
+// next caller fetches the key again. Once the cache is closed, fetched keys are returned but not cached.
+func (c *Cache) GetKey(ctx context.Context, id string, fetch func() (Key, error)) (Key, error) {
+	for {
+		c.mu.Lock()
+		entry, present := c.keys[id]
+		if present && !entry.fetched {
+			c.mu.Unlock()
+			select {
+			case <-entry.ready:
+			case <-ctx.Done():
+				return Key{}, ctx.Err()
+			}
+			continue
+		}
+		if present && !c.spent(entry) {
+			entry.uses++
+			key := entry.copy()
+			if c.maxUses > 0 && entry.uses >= c.maxUses {
+				entry.wipe()
+				delete(c.keys, id)
+			}
+			c.mu.Unlock()
+			return key, nil
+		}
+		if present {
+			entry.wipe()
+			delete(c.keys, id)
+		}
+		if c.closed {
+			c.mu.Unlock()
+			return fetch()
+		}
+		entry = &cachedKey{ready: make(chan struct{})}
+		c.keys[id] = entry
+		c.mu.Unlock()
+
+		key, err := fetch()
+		return key, c.store(id, entry, key, err)
+	}
+}
+
+// store fills entry with the key fetched for id, or removes it if the fetch failed, and wakes its waiters.
+func (c *Cache) store(id string, entry *cachedKey, key Key, err error) error {
+	var cached []byte
+	locked := false
+	if err == nil {
+		cached = append([]byte{}, key.Plaintext...)
+		locked = lockMemory(cached) == nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer close(entry.ready)
+	current := c.keys[id] == entry
+	if err != nil || c.closed || c.maxUses == 1 {
+		if current {
+			delete(c.keys, id)
+		}
+		entry.key.Plaintext, entry.locked = cached, locked
+		entry.wipe()
+		return err
+	}
+	if !locked && !c.lockWarned {
+		fmt.Fprintf(os.Stderr, "Warning: unable to lock cached keys in memory; they may be swapped to disk.\n")
+		c.lockWarned = true
+	}
+	entry.key = key
+	entry.key.Plaintext, entry.locked = cached, locked
+	entry.fetched = true
+	entry.created = c.now()
+	entry.uses = 1
+	return nil
+}
+
+// spent reports whether a fetched key has reached its age or use limit. The caller must hold c.mu.
+func (c *Cache) spent(entry *cachedKey) bool {
+	if c.maxAge > 0 && !c.now().Before(entry.created.Add(c.maxAge)) {
+		return true
+	}
+	return c.maxUses > 0 && entry.uses >= c.maxUses
+}
+
+// Expire wipes and removes the keys that have reached their age limit.
+func (c *Cache) Expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, entry := range c.keys {
+		if entry.fetched && c.spent(entry) {
+			entry.wipe()
+			delete(c.keys, id)
+		}
+	}
+}
+
+// Len returns the number of cached keys, including any that have reached a limit but not yet been removed.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, entry := range c.keys {
+		if entry.fetched {
+			n++
+		}
+	}
+	return n
+}
+
+// Close wipes and removes every key. Keys fetched after Close, including those being fetched when it is
+// called, are not cached.
+func (c *Cache) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for id, entry := range c.keys {
+		if entry.fetched {
+			entry.wipe()
+			delete(c.keys, id)
+		}
+	}
+}
+
+func (k *cachedKey) copy() Key {
+	key := k.key
+	key.Plaintext = append([]byte{}, k.key.Plaintext...)
+	return key
+}
+
+func (k *cachedKey) wipe() {
+	for i := range k.key.Plaintext {
+		k.key.Plaintext[i] = 0
+	}
+	if k.locked {
+		unlockMemory(k.key.Plaintext)
+		k.locked = false
+	}
+}
@@ -0,0 +1,161 @@
+package keycache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_MaxAge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	cache := New(time.Minute, 0)
+	cache.now = func() time.Time { return now }
+	calls := 0
+	decrypt := func() ([]byte, error) {
+		calls++
+		return []byte("key"), nil
+	}
+
+	key, err := cache.Get(ctx, "id", decrypt)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("key"), key)
+	key[0] = 'x'
+	key, err = cache.Get(ctx, "id", decrypt)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("key"), key, "callers should receive a copy")
+	assert.Equal(t, 1, calls)
+
+	now = now.Add(time.Minute)
+	_, err = cache.Get(ctx, "id", decrypt)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	cached := cache.keys["id"].key.Plaintext
+	now = now.Add(time.Minute)
+	cache.Expire()
+	assert.Equal(t, 0, cache.Len())
+	assert.Equal(t, []byte{0, 0, 0}, cached)
+}
+
+func TestCache_MaxUses(t *testing.T) {
+	ctx := context.Background()
+	cache := New(0, 3)
+	calls := 0
+	fetch := func() (Key, error) {
+		calls++
+		return Key{Plaintext: []byte{byte(calls)}, Ciphertext: []byte("blob")}, nil
+	}
+
+	var keys []byte
+	for i := 0; i < 7; i++ {
+		key, err := cache.GetKey(ctx, "id", fetch)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("blob"), key.Ciphertext)
+		keys = append(keys, key.Plaintext...)
+	}
+	assert.Equal(t, []byte{1, 1, 1, 2, 2, 2, 3}, keys)
+	assert.Equal(t, 3, calls)
+}
+
+func TestCache_Errors(t *testing.T) {
+	ctx := context.Background()
+	cache := New(time.Hour, 0)
+	_, err := cache.Get(ctx, "id", func() ([]byte, error) { return nil, errors.New("denied") })
+	assert.EqualError(t, err, "denied")
+	assert.Equal(t, 0, cache.Len())
+
+	key, err := cache.Get(ctx, "id", func() ([]byte, error) { return []byte("key"), nil })
+	require.NoError(t, err)
+	assert.Equal(t, []byte("key"), key)
+}
+
+func TestCache_Close(t *testing.T) {
+	ctx := context.Background()
+	cache := New(time.Hour, 0)
+	_, err := cache.Get(ctx, "id", func() ([]byte, error) { return []byte("key"), nil })
+	require.NoError(t, err)
+	cached := cache.keys["id"].key.Plaintext
+	cache.Close()
+	assert.Equal(t, []byte{0, 0, 0}, cached)
+	assert.Equal(t, 0, cache.Len())
+
+	key, err := cache.Get(ctx, "id", func() ([]byte, error) { return []byte("key"), nil })
+	require.NoError(t, err)
+	assert.Equal(t, []byte("key"), key)
+	assert.Equal(t, 0, cache.Len(), "keys fetched after Close should not be cached")
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	cache := New(0, 0)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	calls := 0
+	decrypt := func() ([]byte, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		<-release
+		return []byte("key"), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := cache.Get(ctx, "id", decrypt)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("key"), key)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, 1, calls)
+}
+
+func TestCache_CanceledWaiters(t *testing.T) {
+	cache := New(0, 0)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := cache.Get(firstCtx, "id", func() ([]byte, error) {
+			close(started)
+			<-release
+			return nil, firstCtx.Err()
+		})
+		first <- err
+	}()
+	<-started
+
+	// A waiter whose own context is done stops waiting.
+	waiterCtx, cancelWaiter := context.WithCancel(context.Background())
+	cancelWaiter()
+	_, err := cache.Get(waiterCtx, "id", func() ([]byte, error) {
+		t.Error("a canceled waiter should not fetch")
+		return nil, nil
+	})
+	assert.Equal(t, context.Canceled, err)
+
+	// Canceling the first caller fails its fetch, which is not cached: a waiter fetches the key itself.
+	second := make(chan []byte)
+	go func() {
+		key, err := cache.Get(context.Background(), "id", func() ([]byte, error) { return []byte("key"), nil })
+		assert.NoError(t, err)
+		second <- key
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancelFirst()
+	close(release)
+	assert.Equal(t, context.Canceled, <-first)
+	assert.Equal(t, []byte("key"), <-second)
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd && !openbsd && !netbsd
+// +build !linux,!darwin,!freebsd,!openbsd,!netbsd
+
+package keycache
+
+import (
+	"errors"
+)
+
+// lockMemory is not supported on this platform.
+func lockMemory(b []byte) error {
+	return errors.New("locking memory is not supported on this platform")
+}
+
+func unlockMemory(b []byte) {}
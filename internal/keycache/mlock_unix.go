@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd
+// +build linux darwin freebsd openbsd netbsd
+
+package keycache
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// lockMemory prevents b from being swapped to disk. Failures, such as exceeding RLIMIT_MEMLOCK, are
+// reported but not fatal.
+func lockMemory(b []byte) error {
+	return unix.Mlock(b)
+}
+
+func unlockMemory(b []byte) {
+	unix.Munlock(b)
+}
@@ -0,0 +1,149 @@
+package keymanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+)
+
+const (
+	// AgeLabel is the label for the age key manager.
+	AgeLabel = "age"
+
+	ageIdentitiesEnv = "BISCUIT_AGE_IDENTITIES"
+)
+
+func init() {
+	registry[AgeLabel] = newAge
+}
+
+var (
+	errNoAgeIdentities = errors.New("age: no identities found. Set " + ageIdentitiesEnv +
+		" to a list of identity files or create ~/.config/biscuit/identities")
+	errAgeSecretMismatch = errors.New("age: key was encrypted for a different secret name")
+
+	ageIdentitiesOnce sync.Once
+	ageIdentities     []age.Identity
+	ageIdentitiesErr  error
+)
+
+// Age is a KeyManager that encrypts envelope keys to age X25519 recipients (age1...). Decryption uses the
+// identities in the files listed in BISCUIT_AGE_IDENTITIES, or ~/.config/biscuit/identities.
+type Age struct{}
+
+func newAge(options Options) KeyManager {
+	return &Age{}
+}
+
+// GenerateEnvelopeKey generates an EnvelopeKey encrypted to the recipient keyID.
+func (a *Age) GenerateEnvelopeKey(ctx context.Context, keyID string, secretID string) (EnvelopeKey, error) {
+	recipient, err := age.ParseX25519Recipient(keyID)
+	if err != nil {
+		return EnvelopeKey{}, fmt.Errorf("age: %s: %s", keyID, err)
+	}
+	plaintext := make([]byte, 32)
+	if _, err := rand.Read(plaintext); err != nil {
+		return EnvelopeKey{}, err
+	}
+
+	// age has no associated data, so the secret name is encrypted along with the key and checked on
+	// decryption.
+	var ciphertext bytes.Buffer
+	w, err := age.Encrypt(&ciphertext, recipient)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	if _, err := w.Write(append(append([]byte{}, plaintext...), secretID...)); err != nil {
+		return EnvelopeKey{}, err
+	}
+	if err := w.Close(); err != nil {
+		return EnvelopeKey{}, err
+	}
+	return EnvelopeKey{
+		ResolvedID: recipient.String(),
+		Plaintext:  plaintext,
+		Ciphertext: ciphertext.Bytes(),
+	}, nil
+}
+
+// Decrypt decrypts the encrypted key.
+//noinspection GoUnusedParameter
+func (a *Age) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte,
+	error) {
+	identities, err := loadAgeIdentities()
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(bytes.NewReader(keyCiphertext), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("age: %s", err)
+	}
+	payload, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("age: %s", err)
+	}
+	if len(payload) != 32+len(secretID) || string(payload[32:]) != secretID {
+		return nil, errAgeSecretMismatch
+	}
+	return payload[:32], nil
+}
+
+// Label returns AgeLabel
+func (a *Age) Label() string {
+	return AgeLabel
+}
+
+// loadAgeIdentities returns the configured identities, reading them at most once per process.
+func loadAgeIdentities() ([]age.Identity, error) {
+	ageIdentitiesOnce.Do(func() {
+		ageIdentities, ageIdentitiesErr = readAgeIdentities()
+		if ageIdentitiesErr == nil && len(ageIdentities) == 0 {
+			ageIdentitiesErr = errNoAgeIdentities
+		}
+	})
+	return ageIdentities, ageIdentitiesErr
+}
+
+func readAgeIdentities() ([]age.Identity, error) {
+	var filenames []string
+	if paths := os.Getenv(ageIdentitiesEnv); len(paths) > 0 {
+		filenames = filepath.SplitList(paths)
+	} else {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, errNoAgeIdentities
+		}
+		filename := filepath.Join(home, ".config", "biscuit", "identities")
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return nil, errNoAgeIdentities
+		}
+		filenames = []string{filename}
+	}
+
+	var identities []age.Identity
+	for _, filename := range filenames {
+		if len(strings.TrimSpace(filename)) == 0 {
+			continue
+		}
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := age.ParseIdentities(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("age: %s: %s", filename, err)
+		}
+		identities = append(identities, parsed...)
+	}
+	return identities, nil
+}
@@ -0,0 +1,84 @@
+package keymanager
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func withAgeIdentities(t *testing.T, contents string) {
+	dir, err := ioutil.TempDir("", "biscuit-age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "identities")
+	if err := ioutil.WriteFile(filename, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	previous, present := os.LookupEnv(ageIdentitiesEnv)
+	os.Setenv(ageIdentitiesEnv, filename)
+	ageIdentitiesOnce = sync.Once{}
+	t.Cleanup(func() {
+		if present {
+			os.Setenv(ageIdentitiesEnv, previous)
+		} else {
+			os.Unsetenv(ageIdentitiesEnv)
+		}
+		os.RemoveAll(dir)
+	})
+}
+
+func TestAge_RoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	km := newAge(Options{})
+	key, err := km.GenerateEnvelopeKey(context.Background(), identity.Recipient().String(), "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.ResolvedID != identity.Recipient().String() {
+		t.Errorf("expected %s, got %s", identity.Recipient(), key.ResolvedID)
+	}
+
+	withAgeIdentities(t, "# created for testing\n"+identity.String()+"\n")
+	plaintext, err := km.Decrypt(context.Background(), key.ResolvedID, key.Ciphertext, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, key.Plaintext) {
+		t.Error("decrypted key does not match")
+	}
+	if _, err := km.Decrypt(context.Background(), key.ResolvedID, key.Ciphertext, "other"); err != errAgeSecretMismatch {
+		t.Errorf("expected %v, got %v", errAgeSecretMismatch, err)
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	withAgeIdentities(t, other.String()+"\n")
+	if _, err := km.Decrypt(context.Background(), key.ResolvedID, key.Ciphertext, "name"); err == nil {
+		t.Error("expected an error when decrypting with the wrong identity")
+	}
+}
+
+func TestAge_InvalidRecipient(t *testing.T) {
+	if _, err := newAge(Options{}).GenerateEnvelopeKey(context.Background(), "alias/biscuit-default", "name"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestAge_NoIdentities(t *testing.T) {
+	withAgeIdentities(t, "# no identities\n")
+	if _, err := newAge(Options{}).Decrypt(context.Background(), "age1", []byte{}, "name"); err == nil {
+		t.Error("expected an error")
+	}
+}
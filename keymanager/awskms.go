@@ -1,41 +1,106 @@
 package keymanager
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/primait/biscuit/internal/keycache"
 	"github.com/primait/biscuit/shared"
+	"golang.org/x/crypto/hkdf"
 )
 
 const (
 	// KmsLabel is the label for the AWS KMS.
 	KmsLabel = "kms"
+
+	// batchKeyPrefix starts the key ciphertext of secrets encrypted under a batch data key. KMS ciphertext
+	// blobs never start with a zero byte.
+	batchKeyPrefix = "\x00biscuit-batch-v1\x00"
+	batchSaltSize  = 32
 )
 
+var errShortBatchKey = errors.New("batch key ciphertext is too short")
+
 func init() {
 	registry[KmsLabel] = NewKms
 }
 
-// Kms is a KeyManager for AWS KMS.
-type Kms struct{}
+// Kms is a KeyManager for AWS KMS. The zero value calls KMS for every data key.
+type Kms struct {
+	// CallTimeout, if positive, bounds each call to KMS, including the SDK's retries.
+	CallTimeout time.Duration
+	// Cache, if set, keeps the data keys returned by KMS Decrypt, so that values whose key ciphertext and
+	// encryption context were seen before are decrypted without calling KMS.
+	Cache *keycache.Cache
+	// Batch, if set, makes GenerateEnvelopeKey reuse one KMS data key per master key for as long as the
+	// cache allows, deriving a different key for each secret from it. The batch data keys are not bound to
+	// the SecretName encryption context, so GenerateEnvelopeKey refuses to batch keys whose policy or
+	// grants refer to it.
+	Batch *keycache.Cache
+}
 
-// NewKms returns a new Kms.
-func NewKms() KeyManager {
-	return &Kms{}
+// NewKms returns a new Kms configured with options. Key managers created with the same options share their
+// caches.
+func NewKms(options Options) KeyManager {
+	return &Kms{CallTimeout: options.CallTimeout, Cache: options.Cache, Batch: options.Batch}
 }
 
 // GenerateEnvelopeKey generates an EnvelopeKey under a specific KeyID.
-func (k *Kms) GenerateEnvelopeKey(keyID string, secretID string) (EnvelopeKey, error) {
+func (k *Kms) GenerateEnvelopeKey(ctx context.Context, keyID string, secretID string) (EnvelopeKey, error) {
+	if k.Batch != nil {
+		return k.generateBatchKey(ctx, keyID, secretID)
+	}
+	return k.generateDataKey(ctx, keyID, map[string]string{"SecretName": secretID})
+}
+
+// generateBatchKey derives a key for secretID from the batch data key of keyID. The key ciphertext holds
+// the random salt of the derivation and the ciphertext of the batch data key.
+func (k *Kms) generateBatchKey(ctx context.Context, keyID string, secretID string) (EnvelopeKey, error) {
+	batch, err := k.Batch.GetKey(ctx, keyID, func() (keycache.Key, error) {
+		key, err := k.generateDataKey(ctx, keyID, batchContext())
+		if err == nil {
+			err = k.checkBatchable(ctx, key.ResolvedID)
+		}
+		return keycache.Key(key), err
+	})
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	salt := make([]byte, batchSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return EnvelopeKey{}, err
+	}
+	plaintext, err := deriveBatchKey(batch.Plaintext, salt, secretID)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	ciphertext := append(append([]byte(batchKeyPrefix), salt...), batch.Ciphertext...)
+	return EnvelopeKey{ResolvedID: batch.ResolvedID, Plaintext: plaintext, Ciphertext: ciphertext}, nil
+}
+
+func (k *Kms) generateDataKey(ctx context.Context, keyID string, encryptionContext map[string]string) (
+	EnvelopeKey, error) {
 	client, err := newKmsClient(keyID)
 	if err != nil {
 		return EnvelopeKey{}, err
 	}
+	ctx, cancel := k.callContext(ctx)
+	defer cancel()
 	generateDataKeyInput := &kms.GenerateDataKeyInput{
-		KeyId: aws.String(keyID),
-		EncryptionContext: aws.StringMap(map[string]string{
-			"SecretName": secretID,
-		}),
-		NumberOfBytes: aws.Int64(32)}
-	generateDataKeyOutput, err := client.GenerateDataKey(generateDataKeyInput)
+		KeyId:             aws.String(keyID),
+		EncryptionContext: aws.StringMap(encryptionContext),
+		NumberOfBytes:     aws.Int64(32)}
+	generateDataKeyOutput, err := client.GenerateDataKeyWithContext(ctx, generateDataKeyInput)
 	if err != nil {
 		return EnvelopeKey{}, err
 	}
@@ -46,18 +111,87 @@ func (k *Kms) GenerateEnvelopeKey(keyID string, secretID string) (EnvelopeKey, e
 }
 
 // Decrypt decrypts the encrypted key.
-func (k *Kms) Decrypt(keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
-	client, err := newKmsClient(keyID)
+func (k *Kms) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+	if !bytes.HasPrefix(keyCiphertext, []byte(batchKeyPrefix)) {
+		return k.decryptDataKey(ctx, keyID, keyCiphertext, map[string]string{"SecretName": secretID})
+	}
+	keyCiphertext = keyCiphertext[len(batchKeyPrefix):]
+	if len(keyCiphertext) <= batchSaltSize {
+		return nil, errShortBatchKey
+	}
+	salt, blob := keyCiphertext[:batchSaltSize], keyCiphertext[batchSaltSize:]
+	batch, err := k.decryptDataKey(ctx, keyID, blob, batchContext())
 	if err != nil {
 		return nil, err
 	}
-	do, err := client.Decrypt(&kms.DecryptInput{
-		EncryptionContext: aws.StringMap(map[string]string{
-			"SecretName": secretID,
-		}),
-		CiphertextBlob: keyCiphertext,
+	return deriveBatchKey(batch, salt, secretID)
+}
+
+// decryptDataKey calls KMS Decrypt, or returns the plaintext from Cache if it is set.
+func (k *Kms) decryptDataKey(ctx context.Context, keyID string, blob []byte, encryptionContext map[string]string) (
+	[]byte, error) {
+	decrypt := func() ([]byte, error) {
+		client, err := newKmsClient(keyID)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := k.callContext(ctx)
+		defer cancel()
+		do, err := client.DecryptWithContext(ctx, &kms.DecryptInput{
+			EncryptionContext: aws.StringMap(encryptionContext),
+			CiphertextBlob:    blob,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return do.Plaintext, nil
+	}
+	if k.Cache == nil {
+		return decrypt()
+	}
+	return k.Cache.Get(ctx, decryptCacheID(keyID, blob, encryptionContext), decrypt)
+}
+
+// checkBatchable returns an error if the key policy or a grant of keyID refers to the SecretName
+// encryption context, which batch data keys are not bound to. Batching such a key would make its secrets
+// unreadable to the principals that are only allowed to decrypt some of them.
+func (k *Kms) checkBatchable(ctx context.Context, keyID string) error {
+	client, err := newKmsClient(keyID)
+	if err != nil {
+		return err
+	}
+	policyCtx, cancel := k.callContext(ctx)
+	defer cancel()
+	policy, err := client.GetKeyPolicyWithContext(policyCtx, &kms.GetKeyPolicyInput{
+		KeyId:      aws.String(keyID),
+		PolicyName: aws.String("default"),
 	})
-	return do.Plaintext, err
+	if err != nil {
+		return fmt.Errorf("unable to check whether %s restricts access by SecretName: %s", keyID, err)
+	}
+	restricted := strings.Contains(strings.ToLower(aws.StringValue(policy.Policy)), "secretname")
+
+	grantsCtx, cancel := k.callContext(ctx)
+	defer cancel()
+	err = client.ListGrantsPagesWithContext(grantsCtx, &kms.ListGrantsInput{KeyId: aws.String(keyID)},
+		func(page *kms.ListGrantsResponse, last bool) bool {
+			for _, grant := range page.Grants {
+				if constraints := grant.Constraints; constraints != nil {
+					_, equals := constraints.EncryptionContextEquals["SecretName"]
+					_, subset := constraints.EncryptionContextSubset["SecretName"]
+					restricted = restricted || equals || subset
+				}
+			}
+			return !restricted
+		})
+	if err != nil {
+		return fmt.Errorf("unable to check whether %s restricts access by SecretName: %s", keyID, err)
+	}
+	if restricted {
+		return fmt.Errorf("%s restricts access by SecretName, so its data keys cannot be reused across secrets",
+			keyID)
+	}
+	return nil
 }
 
 // Label returns kmsLabel
@@ -65,6 +199,31 @@ func (k *Kms) Label() string {
 	return KmsLabel
 }
 
+// batchContext is the encryption context of batch data keys.
+func batchContext() map[string]string {
+	return map[string]string{"BiscuitDataKey": "batch"}
+}
+
+// deriveBatchKey derives the key of one secret from a batch data key with HKDF-SHA256.
+func deriveBatchKey(batch, salt []byte, secretID string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, batch, salt, []byte(batchKeyPrefix+secretID)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// decryptCacheID identifies a KMS Decrypt call by everything KMS checks: the key, the ciphertext blob and
+// the encryption context.
+func decryptCacheID(keyID string, blob []byte, encryptionContext map[string]string) string {
+	var pairs []string
+	for name, value := range encryptionContext {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(append([]string{keyID, string(blob)}, pairs...), "\x00")
+}
+
 func newKmsClient(arn string) (*kms.KMS, error) {
 	parsed, err := NewARN(arn)
 	if err != nil {
@@ -72,3 +231,11 @@ func newKmsClient(arn string) (*kms.KMS, error) {
 	}
 	return kms.New(shared.GetNewSessionWithRegion(parsed.Region)), nil
 }
+
+// callContext returns the context for one call to KMS, bounded by CallTimeout.
+func (k *Kms) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if k.CallTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, k.CallTimeout)
+}
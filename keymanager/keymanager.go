@@ -1,14 +1,40 @@
 package keymanager
 
 import (
+	"context"
 	"fmt"
 	"sort"
+	"strings"
+	"time"
+
+	"github.com/primait/biscuit/internal/keycache"
 )
 
+// DefaultCallTimeout bounds each call to a remote key manager unless Options say otherwise.
+const DefaultCallTimeout = 10 * time.Second
+
 var (
-	registry = make(map[string]func() KeyManager)
+	registry = make(map[string]func(options Options) KeyManager)
 )
 
+// Options configure the key managers returned by Options.New. The zero value does not limit calls and does
+// not cache data keys.
+type Options struct {
+	// CallTimeout bounds each call to AWS KMS, including the SDK's retries, so that a call to an unreachable
+	// region fails in time for the next value to be tried. Zero means that calls are only bounded by their
+	// context.
+	CallTimeout time.Duration
+	// Cache, if set, keeps the data keys returned by KMS Decrypt; see Kms.Cache.
+	Cache *keycache.Cache
+	// Batch, if set, makes KMS reuse data keys when encrypting; see Kms.Batch.
+	Batch *keycache.Cache
+}
+
+// DefaultOptions returns the options used by New.
+func DefaultOptions() Options {
+	return Options{CallTimeout: DefaultCallTimeout}
+}
+
 type errUnsupportedKeyManager struct {
 	label string
 }
@@ -17,10 +43,15 @@ func (e *errUnsupportedKeyManager) Error() string {
 	return fmt.Sprintf("unsupported key manager '%s'", e.label)
 }
 
-// New returns a KeyManager of the requested type.
+// New returns a KeyManager of the requested type, configured with DefaultOptions.
 func New(label string) (KeyManager, error) {
+	return DefaultOptions().New(label)
+}
+
+// New returns a KeyManager of the requested type, configured with o.
+func (o Options) New(label string) (KeyManager, error) {
 	if constructor, present := registry[label]; present {
-		return constructor(), nil
+		return constructor(o), nil
 	}
 	return nil, &errUnsupportedKeyManager{label}
 }
@@ -30,6 +61,21 @@ func GetDefaultKeyManager() string {
 	return KmsLabel
 }
 
+// ForKeyID returns the label of the key manager that keyID belongs to, judging by its format, or fallback if
+// the format is not recognized. ex: age1... is an age recipient, and arn:aws:kms:... is a KMS key.
+func ForKeyID(keyID, fallback string) string {
+	switch {
+	case strings.HasPrefix(keyID, "age1"):
+		return AgeLabel
+	case strings.HasPrefix(keyID, argon2idPrefix):
+		return PassphraseLabel
+	}
+	if _, err := NewARN(keyID); err == nil {
+		return KmsLabel
+	}
+	return fallback
+}
+
 // GetKeyManagers returns a list of registered key managers.
 func GetKeyManagers() []string {
 	var collector []string
@@ -41,10 +87,10 @@ func GetKeyManagers() []string {
 }
 
 // KeyManager represents a service that can generate envelope keys and provide decryption
-// keys.
+// keys. Key managers that call remote services abandon the call when ctx is done.
 type KeyManager interface {
-	GenerateEnvelopeKey(keyID, secretID string) (EnvelopeKey, error)
-	Decrypt(keyID string, keyMetadata []byte, secretID string) ([]byte, error)
+	GenerateEnvelopeKey(ctx context.Context, keyID, secretID string) (EnvelopeKey, error)
+	Decrypt(ctx context.Context, keyID string, keyMetadata []byte, secretID string) ([]byte, error)
 	Label() string
 }
 
@@ -0,0 +1,25 @@
+package keymanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/primait/biscuit/internal/keycache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptions_New(t *testing.T) {
+	km, err := New(KmsLabel)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultCallTimeout, km.(*Kms).CallTimeout)
+
+	cache := keycache.New(time.Minute, 0)
+	km, err = Options{CallTimeout: time.Second, Cache: cache}.New(KmsLabel)
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, km.(*Kms).CallTimeout)
+	assert.Equal(t, cache, km.(*Kms).Cache)
+
+	_, err = Options{}.New("unknown")
+	assert.Error(t, err)
+}
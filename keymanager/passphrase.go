@@ -0,0 +1,267 @@
+package keymanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/term"
+)
+
+const (
+	// PassphraseLabel is the label for the passphrase key manager.
+	PassphraseLabel = "passphrase"
+
+	passphraseEnv     = "BISCUIT_PASSPHRASE"
+	passphraseFileEnv = "BISCUIT_PASSPHRASE_FILE"
+	argon2idPrefix    = "argon2id:"
+	saltSize          = 16
+
+	// maxArgon2idMemory caps the memory parameter, in KiB, read from a key ID at 4 GiB, so that an edited
+	// file cannot make biscuit allocate an unbounded amount of memory.
+	maxArgon2idMemory = 4 * 1024 * 1024
+	maxArgon2idTime   = 1024
+)
+
+func init() {
+	registry[PassphraseLabel] = newPassphrase
+}
+
+var (
+	errNoPassphrase = errors.New("passphrase: no passphrase available. Set " + passphraseEnv + " or " +
+		passphraseFileEnv + ", or run from a terminal")
+	errEmptyPassphrase    = errors.New("passphrase: the passphrase must not be empty")
+	errInvalidCiphertext  = errors.New("passphrase: key ciphertext too short")
+	errUnableToUnwrapKey  = errors.New("passphrase: unable to decrypt key; is the passphrase correct?")
+	defaultArgon2idParams = argon2idParams{memory: 64 * 1024, time: 3, threads: 4}
+
+	passphraseOnce sync.Once
+	passphrase     []byte
+	passphraseErr  error
+
+	// keyEncryptionKeys caches derived keys by KeyID so that each salt is only stretched once per process.
+	// generatedParams holds the salt generated for each key ID that does not have one, so that the secrets
+	// written by one process share a single derivation. Both are guarded by keyEncryptionKeysMu.
+	keyEncryptionKeys   = make(map[string]*derivedKey)
+	generatedParams     = make(map[string]argon2idParams)
+	keyEncryptionKeysMu sync.Mutex
+)
+
+// derivedKey is a key-encryption key that is derived once, by whichever caller needs it first.
+type derivedKey struct {
+	once sync.Once
+	kek  []byte
+	err  error
+}
+
+// argon2idParams are the Argon2id parameters and salt used to derive a key-encryption key. They are
+// serialized into the KeyID, ex: argon2id:v=19,m=65536,t=3,p=4:c2FsdHNhbHRzYWx0c2FsdA
+type argon2idParams struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+}
+
+func parseArgon2idKeyID(keyID string) (argon2idParams, error) {
+	var params argon2idParams
+	var version, memory, time, threads int64
+	fields := strings.SplitN(strings.TrimPrefix(keyID, argon2idPrefix), ":", 2)
+	if !strings.HasPrefix(keyID, argon2idPrefix) || len(fields) != 2 {
+		return params, fmt.Errorf("passphrase: %s: key ID must be of the form %sv=19,m=65536,t=3,p=4:SALT",
+			keyID, argon2idPrefix)
+	}
+	if _, err := fmt.Sscanf(fields[0], "v=%d,m=%d,t=%d,p=%d", &version, &memory, &time, &threads); err != nil {
+		return params, fmt.Errorf("passphrase: %s: invalid parameters: %s", keyID, err)
+	}
+	if version != argon2.Version {
+		return params, fmt.Errorf("passphrase: %s: unsupported argon2 version %d", keyID, version)
+	}
+	// argon2.IDKey panics if t or p is zero, and allocates m KiB.
+	if threads < 1 || threads > 255 {
+		return params, fmt.Errorf("passphrase: %s: p must be between 1 and 255", keyID)
+	}
+	if time < 1 || time > maxArgon2idTime {
+		return params, fmt.Errorf("passphrase: %s: t must be between 1 and %d", keyID, maxArgon2idTime)
+	}
+	if memory < 8*threads || memory > maxArgon2idMemory {
+		return params, fmt.Errorf("passphrase: %s: m must be between 8*p and %d KiB", keyID, maxArgon2idMemory)
+	}
+	params.memory, params.time, params.threads = uint32(memory), uint32(time), uint8(threads)
+	salt, err := base64.RawURLEncoding.DecodeString(fields[1])
+	if err != nil || len(salt) == 0 {
+		return params, fmt.Errorf("passphrase: %s: invalid salt", keyID)
+	}
+	params.salt = salt
+	return params, nil
+}
+
+func (p argon2idParams) String() string {
+	return fmt.Sprintf("%sv=%d,m=%d,t=%d,p=%d:%s", argon2idPrefix, argon2.Version, p.memory, p.time, p.threads,
+		base64.RawURLEncoding.EncodeToString(p.salt))
+}
+
+// Passphrase is a KeyManager that wraps envelope keys with a key derived from a passphrase using Argon2id.
+// The passphrase is read from BISCUIT_PASSPHRASE, from the file named by BISCUIT_PASSPHRASE_FILE, or from
+// the terminal.
+type Passphrase struct{}
+
+func newPassphrase(options Options) KeyManager {
+	return &Passphrase{}
+}
+
+// GenerateEnvelopeKey generates an EnvelopeKey. If keyID is an argon2id key ID, its salt and parameters are
+// reused, and it must be valid; otherwise a new salt is generated with the default parameters the first time
+// keyID is used in this process.
+func (p *Passphrase) GenerateEnvelopeKey(ctx context.Context, keyID string, secretID string) (EnvelopeKey, error) {
+	var params argon2idParams
+	if strings.HasPrefix(keyID, argon2idPrefix) {
+		var err error
+		if params, err = parseArgon2idKeyID(keyID); err != nil {
+			return EnvelopeKey{}, err
+		}
+	} else {
+		var err error
+		if params, err = generateArgon2idParams(keyID); err != nil {
+			return EnvelopeKey{}, err
+		}
+	}
+	resolvedID := params.String()
+	kek, err := keyEncryptionKey(resolvedID, params)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+
+	plaintext := make([]byte, 32)
+	if _, err := rand.Read(plaintext); err != nil {
+		return EnvelopeKey{}, err
+	}
+	aead, err := chacha20poly1305.NewX(kek)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return EnvelopeKey{}, err
+	}
+	return EnvelopeKey{
+		ResolvedID: resolvedID,
+		Plaintext:  plaintext,
+		Ciphertext: aead.Seal(nonce, nonce, plaintext, []byte(secretID)),
+	}, nil
+}
+
+// Decrypt decrypts the encrypted key.
+func (p *Passphrase) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+	params, err := parseArgon2idKeyID(keyID)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := keyEncryptionKey(keyID, params)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(keyCiphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, errInvalidCiphertext
+	}
+	nonce := keyCiphertext[:aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, keyCiphertext[aead.NonceSize():], []byte(secretID))
+	if err != nil {
+		return nil, errUnableToUnwrapKey
+	}
+	return plaintext, nil
+}
+
+// Label returns PassphraseLabel
+func (p *Passphrase) Label() string {
+	return PassphraseLabel
+}
+
+// generateArgon2idParams returns the default parameters with a random salt, generating the salt the first
+// time it is called for keyID.
+func generateArgon2idParams(keyID string) (argon2idParams, error) {
+	keyEncryptionKeysMu.Lock()
+	defer keyEncryptionKeysMu.Unlock()
+	if params, present := generatedParams[keyID]; present {
+		return params, nil
+	}
+	params := defaultArgon2idParams
+	params.salt = make([]byte, saltSize)
+	if _, err := rand.Read(params.salt); err != nil {
+		return params, err
+	}
+	generatedParams[keyID] = params
+	return params, nil
+}
+
+// keyEncryptionKey returns the key derived from the passphrase with params, deriving it once per resolved
+// keyID. Derivations for different salts run concurrently.
+func keyEncryptionKey(keyID string, params argon2idParams) ([]byte, error) {
+	keyEncryptionKeysMu.Lock()
+	derived, present := keyEncryptionKeys[keyID]
+	if !present {
+		derived = &derivedKey{}
+		keyEncryptionKeys[keyID] = derived
+	}
+	keyEncryptionKeysMu.Unlock()
+
+	derived.once.Do(func() {
+		secret, err := readPassphrase()
+		if err != nil {
+			derived.err = err
+			return
+		}
+		derived.kek = argon2.IDKey(secret, params.salt, params.time, params.memory, params.threads,
+			chacha20poly1305.KeySize)
+	})
+	return derived.kek, derived.err
+}
+
+// readPassphrase returns the passphrase, reading it at most once per process.
+func readPassphrase() ([]byte, error) {
+	passphraseOnce.Do(func() {
+		passphrase, passphraseErr = findPassphrase()
+		if passphraseErr == nil && len(passphrase) == 0 {
+			passphraseErr = errEmptyPassphrase
+		}
+	})
+	return passphrase, passphraseErr
+}
+
+func findPassphrase() ([]byte, error) {
+	if value, present := os.LookupEnv(passphraseEnv); present {
+		return []byte(value), nil
+	}
+	if filename := os.Getenv(passphraseFileEnv); len(filename) > 0 {
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimRight(contents, "\r\n"), nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, errNoPassphrase
+		}
+		tty = os.Stdin
+	} else {
+		defer tty.Close()
+	}
+	fmt.Fprintf(os.Stderr, "Passphrase: ")
+	defer fmt.Fprintf(os.Stderr, "\n")
+	return term.ReadPassword(int(tty.Fd()))
+}
@@ -0,0 +1,142 @@
+package keymanager
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testArgon2idKeyID = "argon2id:v=19,m=64,t=1,p=1:c2FsdHNhbHRzYWx0c2FsdA"
+
+func withPassphrase(t *testing.T, secret string) {
+	previous, present := os.LookupEnv(passphraseEnv)
+	os.Setenv(passphraseEnv, secret)
+	t.Cleanup(func() {
+		if present {
+			os.Setenv(passphraseEnv, previous)
+		} else {
+			os.Unsetenv(passphraseEnv)
+		}
+	})
+	passphraseOnce = sync.Once{}
+	keyEncryptionKeys = make(map[string]*derivedKey)
+	generatedParams = make(map[string]argon2idParams)
+}
+
+func TestParseArgon2idKeyID(t *testing.T) {
+	params, err := parseArgon2idKeyID(testArgon2idKeyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.memory != 64 || params.time != 1 || params.threads != 1 || string(params.salt) != "saltsaltsaltsalt" {
+		t.Errorf("unexpected params: %+v", params)
+	}
+	if params.String() != testArgon2idKeyID {
+		t.Errorf("expected %s, got %s", testArgon2idKeyID, params.String())
+	}
+
+	for _, keyID := range []string{
+		"",
+		"alias/biscuit",
+		"argon2id:v=19,m=64,t=1,p=1",
+		"argon2id:v=16,m=64,t=1,p=1:c2FsdA",
+		"argon2id:m=64:c2FsdA",
+		"argon2id:v=19,m=64,t=1,p=1:",
+		"argon2id:v=19,m=64,t=0,p=1:c2FsdA",
+		"argon2id:v=19,m=64,t=1,p=0:c2FsdA",
+		"argon2id:v=19,m=4096,t=1,p=256:c2FsdA",
+		"argon2id:v=19,m=64,t=-1,p=1:c2FsdA",
+		"argon2id:v=19,m=8,t=1,p=2:c2FsdA",
+		"argon2id:v=19,m=4194305,t=1,p=1:c2FsdA",
+		"argon2id:v=19,m=99999999999,t=1,p=1:c2FsdA",
+		"argon2id:v=19,m=64,t=1025,p=1:c2FsdA",
+	} {
+		if _, err := parseArgon2idKeyID(keyID); err == nil {
+			t.Errorf("%q: expected an error", keyID)
+		}
+	}
+}
+
+func TestPassphrase_RoundTrip(t *testing.T) {
+	withPassphrase(t, "correct horse battery staple")
+	km := newPassphrase(Options{})
+
+	key, err := km.GenerateEnvelopeKey(context.Background(), testArgon2idKeyID, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.ResolvedID != testArgon2idKeyID {
+		t.Errorf("expected %s, got %s", testArgon2idKeyID, key.ResolvedID)
+	}
+	plaintext, err := km.Decrypt(context.Background(), key.ResolvedID, key.Ciphertext, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, key.Plaintext) {
+		t.Error("decrypted key does not match")
+	}
+	if _, err := km.Decrypt(context.Background(), key.ResolvedID, key.Ciphertext, "other"); err == nil {
+		t.Error("expected an error when decrypting under a different secret name")
+	}
+
+	withPassphrase(t, "wrong")
+	if _, err := km.Decrypt(context.Background(), key.ResolvedID, key.Ciphertext, "name"); err != errUnableToUnwrapKey {
+		t.Errorf("expected %v, got %v", errUnableToUnwrapKey, err)
+	}
+}
+
+func TestPassphrase_GeneratesSalt(t *testing.T) {
+	withPassphrase(t, "passphrase")
+	defaults := defaultArgon2idParams
+	defaultArgon2idParams = argon2idParams{memory: 64, time: 1, threads: 1}
+	defer func() { defaultArgon2idParams = defaults }()
+
+	km := newPassphrase(Options{})
+	first, err := km.GenerateEnvelopeKey(context.Background(), "", "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := km.GenerateEnvelopeKey(context.Background(), "", "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(first.ResolvedID, "argon2id:v=19,m=64,t=1,p=1:") {
+		t.Errorf("unexpected key ID %s", first.ResolvedID)
+	}
+	if first.ResolvedID != second.ResolvedID {
+		t.Error("expected the salt to be reused within a process")
+	}
+	other, err := km.GenerateEnvelopeKey(context.Background(), "argon2id", "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.ResolvedID == first.ResolvedID {
+		t.Error("expected a fresh salt for another key ID")
+	}
+	if len(keyEncryptionKeys) != 2 {
+		t.Errorf("expected one derivation per salt, got %d", len(keyEncryptionKeys))
+	}
+}
+
+func TestPassphrase_InvalidKeyID(t *testing.T) {
+	withPassphrase(t, "passphrase")
+	_, err := newPassphrase(Options{}).GenerateEnvelopeKey(context.Background(), "argon2id:v=19,m=64,t=0,p=1:c2FsdA", "name")
+	if err == nil || !strings.Contains(err.Error(), "t must be") {
+		t.Errorf("expected the key ID to be rejected, got %v", err)
+	}
+	_, err = newPassphrase(Options{}).Decrypt(context.Background(), "argon2id:v=19,m=64,t=1,p=0:c2FsdA", nil, "name")
+	if err == nil || !strings.Contains(err.Error(), "p must be") {
+		t.Errorf("expected the key ID to be rejected, got %v", err)
+	}
+}
+
+func TestPassphrase_Empty(t *testing.T) {
+	withPassphrase(t, "")
+	_, err := newPassphrase(Options{}).GenerateEnvelopeKey(context.Background(), testArgon2idKeyID, "name")
+	if err != errEmptyPassphrase {
+		t.Errorf("expected %v, got %v", errEmptyPassphrase, err)
+	}
+}
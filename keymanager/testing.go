@@ -2,6 +2,7 @@ package keymanager
 
 import (
 	"bytes"
+	"context"
 )
 
 const (
@@ -22,13 +23,13 @@ var (
 )
 
 // NewTestingKeyManager returns a new testingKeys.
-func newTestingKeyManager() KeyManager {
+func newTestingKeyManager(options Options) KeyManager {
 	return &testingKeys{}
 }
 
 // GenerateEnvelopeKey generates an EnvelopeKey under a specific KeyID.
 //noinspection GoUnusedParameter
-func (k *testingKeys) GenerateEnvelopeKey(keyID, secretID string) (EnvelopeKey, error) {
+func (k *testingKeys) GenerateEnvelopeKey(ctx context.Context, keyID, secretID string) (EnvelopeKey, error) {
 	return EnvelopeKey{
 		ResolvedID: "resolved",
 		Plaintext:  testingPlaintext,
@@ -38,7 +39,8 @@ func (k *testingKeys) GenerateEnvelopeKey(keyID, secretID string) (EnvelopeKey,
 
 // Decrypt decrypts the encrypted key.
 //noinspection GoUnusedParameter
-func (k *testingKeys) Decrypt(keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+func (k *testingKeys) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte,
+	error) {
 	return testingPlaintext, nil
 }
 
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/primait/biscuit/commands"
 	"github.com/primait/biscuit/commands/awskms"
+	"github.com/primait/biscuit/internal/keycache"
+	"github.com/primait/biscuit/keymanager"
 	"github.com/primait/biscuit/shared"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
@@ -16,15 +23,41 @@ var (
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run runs the command and returns the exit status. Deferred cleanup, such as wiping cached data keys, runs
+// before biscuit exits.
+func run() int {
 	os.Setenv("COLUMNS", "80") // hack to make --help output readable
 
 	app := kingpin.New(shared.ProgName, mustAsset(_usageTxt))
 	app.Version(Version)
 	app.UsageTemplate(kingpin.LongHelpTemplate)
+	timeout := app.Flag("timeout", "Abandon the command if it has not finished after this long, ex: 30s. "+
+		"By default there is no limit.").Default("0s").Duration()
+	callTimeout := app.Flag("call-timeout", "Abandon each call to AWS KMS, including retries, after this long "+
+		"and try the next value. 0 disables the limit.").Default(keymanager.DefaultCallTimeout.String()).Duration()
+	dataKeyMaxAge := app.Flag("data-key-max-age", "Keep the data keys decrypted by AWS KMS in memory for up "+
+		"to this long, so that values sharing a data key are decrypted with one call. 0 disables the limit.").
+		Default("0s").Duration()
+	dataKeyMaxUses := app.Flag("data-key-max-uses", "Use each cached data key at most this many times. 0 "+
+		"disables the limit. Data keys are only cached if --data-key-max-age or --data-key-max-uses is set.").
+		Default("0").Int()
 	getFlags := app.Command("get", "Read a secret.")
 	putFlags := app.Command("put", "Write a secret.")
 	listFlags := app.Command("list", "List secrets.")
-	exportFlags := app.Command("export", "Print all secrets to stdout in plaintext YAML.")
+	deleteFlags := app.Command("delete", "Delete secrets.")
+	renameFlags := app.Command("rename", "Rename a secret.")
+	rotateFlags := app.Command("rotate", "Re-encrypt secrets under fresh data keys using the key template.")
+	rekeyFlags := app.Command("rekey", "Add or remove values so that each secret matches the key template.")
+	exportFlags := app.Command("export", "Print secrets in plaintext as YAML, JSON, dotenv and other formats.")
+	importFlags := app.Command("import", "Encrypt secrets from a plaintext YAML, JSON or dotenv document.")
+	execFlags := app.Command("exec", "Run a command with secrets in its environment.")
+	editFlags := app.Command("edit", "Edit secrets in your editor and re-encrypt the changes.")
+	renderFlags := app.Command("render", "Substitute secrets into a Go text/template.")
+	verifyFlags := app.Command("verify", "Check that every value of every secret can be decrypted.")
+	agentFlags := app.Command("agent", "Serve decrypted secrets to other commands over a Unix socket.")
 	kmsFlags := app.Command("kms", "AWS KMS-specific operations.")
 	kmsIDFlags := kmsFlags.Command("get-caller-identity", "Print the AWS credentials.")
 	kmsInitFlags := kmsFlags.Command("init", mustAsset(_kmsinitTxt))
@@ -38,7 +71,17 @@ func main() {
 	getCommand := commands.NewGet(getFlags)
 	writeCommand := commands.NewPut(putFlags)
 	listCommand := commands.NewList(listFlags)
+	deleteCommand := commands.NewDelete(deleteFlags)
+	renameCommand := commands.NewRename(renameFlags)
+	rotateCommand := commands.NewRotate(rotateFlags)
+	rekeyCommand := commands.NewRekey(rekeyFlags)
 	exportCommand := commands.NewExport(exportFlags)
+	importCommand := commands.NewImport(importFlags)
+	execCommand := commands.NewExec(execFlags)
+	editCommand := commands.NewEdit(editFlags)
+	renderCommand := commands.NewRender(renderFlags)
+	verifyCommand := commands.NewVerify(verifyFlags)
+	agentCommand := commands.NewAgent(agentFlags)
 	kmsIDCommand := awskms.KmsGetCallerIdentity{}
 	kmsEditKeyPolicy := awskms.NewKmsEditKeyPolicy(kmsEditKeyPolicyFlags)
 	kmsGrantsListCommand := awskms.NewKmsGrantsList(kmsGrantsListFlags)
@@ -48,36 +91,74 @@ func main() {
 	kmsDeprovisionCommand := awskms.NewKmsDeprovision(kmsDeprovisionFlags)
 
 	behavior := kingpin.MustParse(app.Parse(os.Args[1:]))
+	options := keymanager.Options{CallTimeout: *callTimeout}
+	if *dataKeyMaxAge > 0 || *dataKeyMaxUses > 0 {
+		options.Cache = keycache.New(*dataKeyMaxAge, *dataKeyMaxUses)
+		defer options.Cache.Close()
+	}
+	ctx, cancel := commandContext(*timeout, func() {
+		if options.Cache != nil {
+			options.Cache.Close()
+		}
+	})
+	defer cancel()
+	ctx = commands.WithKeyManagerOptions(ctx, options)
 	var err error
 	switch behavior {
 	case getFlags.FullCommand():
-		err = getCommand.Run()
+		err = getCommand.Run(ctx)
 	case putFlags.FullCommand():
-		err = writeCommand.Run()
+		err = writeCommand.Run(ctx)
 	case listFlags.FullCommand():
-		err = listCommand.Run()
+		err = listCommand.Run(ctx)
+	case deleteFlags.FullCommand():
+		err = deleteCommand.Run(ctx)
+	case renameFlags.FullCommand():
+		err = renameCommand.Run(ctx)
+	case rotateFlags.FullCommand():
+		err = rotateCommand.Run(ctx)
+	case rekeyFlags.FullCommand():
+		err = rekeyCommand.Run(ctx)
 	case kmsIDFlags.FullCommand():
-		err = kmsIDCommand.Run()
+		err = kmsIDCommand.Run(ctx)
 	case kmsInitFlags.FullCommand():
-		err = kmsInitCommand.Run()
+		err = kmsInitCommand.Run(ctx)
 	case kmsEditKeyPolicyFlags.FullCommand():
-		err = kmsEditKeyPolicy.Run()
+		err = kmsEditKeyPolicy.Run(ctx)
 	case kmsGrantsCreateFlags.FullCommand():
-		err = kmsGrantsCreateCommand.Run()
+		err = kmsGrantsCreateCommand.Run(ctx)
 	case kmsGrantsListFlags.FullCommand():
-		err = kmsGrantsListCommand.Run()
+		err = kmsGrantsListCommand.Run(ctx)
 	case kmsDeprovisionFlags.FullCommand():
-		err = kmsDeprovisionCommand.Run()
+		err = kmsDeprovisionCommand.Run(ctx)
 	case kmsGrantsRetireFlags.FullCommand():
-		err = kmsGrantsRetireCommand.Run()
+		err = kmsGrantsRetireCommand.Run(ctx)
 	case exportFlags.FullCommand():
-		err = exportCommand.Run()
+		err = exportCommand.Run(ctx)
+	case importFlags.FullCommand():
+		err = importCommand.Run(ctx)
+	case execFlags.FullCommand():
+		err = execCommand.Run(ctx)
+	case editFlags.FullCommand():
+		err = editCommand.Run(ctx)
+	case renderFlags.FullCommand():
+		err = renderCommand.Run(ctx)
+	case verifyFlags.FullCommand():
+		err = verifyCommand.Run(ctx)
+	case agentFlags.FullCommand():
+		err = agentCommand.Run(ctx)
 	}
 	if err == nil {
-		return
+		return 0
+	}
+	var status commands.ExitStatus
+	if errors.As(err, &status) {
+		return int(status)
 	}
 	fmt.Fprintf(os.Stderr, "%s\n", err)
-	if awsErr, ok := err.(awserr.Error); ok {
+	if ctx.Err() == context.DeadlineExceeded {
+		fmt.Fprintf(os.Stderr, "Hint: The command did not finish within --timeout.\n")
+	} else if awsErr, ok := err.(awserr.Error); ok {
 		switch awsErr.Code() {
 		case "MissingRegion":
 			fmt.Fprintf(os.Stderr, "Hint: Check or set the AWS_REGION environment variable.\n")
@@ -85,9 +166,39 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Hint: Refresh your credentials.\n")
 		case "InvalidCiphertextException":
 			fmt.Fprintf(os.Stderr, "Hint: key_ciphertext may be corrupted.\n")
+		case "RequestCanceled":
+			if ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "Hint: A call to AWS did not finish within --call-timeout.\n")
+			}
+		}
+	}
+	return 1
+}
+
+// commandContext returns the context that commands run in. It is canceled after timeout, if positive, or when
+// biscuit is interrupted, which abandons any calls in flight. A second interrupt calls cleanup and exits
+// immediately.
+func commandContext(timeout time.Duration, cleanup func()) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelAll := cancel
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		cancelAll = func() {
+			cancelTimeout()
+			cancel()
 		}
 	}
-	os.Exit(1)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancelAll()
+		<-signals
+		cleanup()
+		os.Exit(1)
+	}()
+	return ctx, cancelAll
 }
 
 func mustAsset(data []byte) string {
@@ -0,0 +1,109 @@
+package shared
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrNoEditorFound is returned when neither VISUAL nor EDITOR is set.
+var ErrNoEditorFound = errors.New("Set your editor preference with VISUAL or EDITOR environment variables.")
+
+// FindEditor returns the user's preferred editor command, to which the file to edit is appended. A value
+// naming an existing file, such as a path with spaces, is run as is. Otherwise it may include arguments, ex:
+// "code --wait", and is run by the shell as git does, so that it may also quote paths; Windows has no shell,
+// so the value is split on spaces there.
+func FindEditor() ([]string, error) {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		value := strings.TrimSpace(os.Getenv(name))
+		if len(value) == 0 {
+			continue
+		}
+		if info, err := os.Stat(value); err == nil && !info.IsDir() {
+			return []string{value}, nil
+		}
+		if runtime.GOOS == "windows" {
+			return strings.Fields(value), nil
+		}
+		return []string{"sh", "-c", value + ` "$@"`, value}, nil
+	}
+	return nil, ErrNoEditorFound
+}
+
+// LaunchEditor writes contents to a private temporary file, opens it in the user's editor, and returns the
+// contents of the file once the editor exits. The file is named after pattern (see ioutil.TempFile) so
+// that editors can pick a syntax mode from its extension.
+//
+// The file lives in a directory readable only by the current user, on a memory-backed filesystem when one
+// is available, and it is overwritten with zeros before it is removed.
+func LaunchEditor(contents []byte, pattern string) ([]byte, error) {
+	editor, err := FindEditor()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir(privateTempBase(), ProgName)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	f, err := ioutil.TempFile(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeFile(f.Name())
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Write(contents); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(f.Name())
+}
+
+// privateTempBase returns the directory to create private temporary files in, preferring memory-backed
+// filesystems so that plaintext is never written to disk.
+func privateTempBase() string {
+	for _, candidate := range []string{os.Getenv("XDG_RUNTIME_DIR"), "/dev/shm"} {
+		if len(candidate) == 0 {
+			continue
+		}
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate
+		}
+	}
+	return os.TempDir()
+}
+
+// wipeFile overwrites a file with zeros and removes it. Editors commonly replace the file rather than
+// writing it in place, so this only wipes the latest version; it is a best effort.
+func wipeFile(filename string) {
+	defer os.Remove(filename)
+	info, err := os.Stat(filename)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(make([]byte, info.Size())); err == nil {
+		f.Sync()
+	}
+}
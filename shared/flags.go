@@ -1,6 +1,7 @@
 package shared
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -15,9 +16,9 @@ const (
 	ProgName = "biscuit"
 )
 
-// Command types have a Run() method.
+// Command types have a Run() method. ctx is canceled when the user interrupts biscuit or --timeout passes.
 type Command interface {
-	Run() error
+	Run(ctx context.Context) error
 }
 
 // CommaSeparatedList is a configurable flag.Value that parses a comma delimited string into a string array.
@@ -153,7 +154,9 @@ func AlgorithmFlag(cc *kingpin.CmdClause) *string {
 
 // FilenameFlag defines a flag for the filename.
 func FilenameFlag(cc *kingpin.CmdClause) *string {
-	return cc.Flag("filename", "Name of file storing the secrets. If the environment variable BISCUIT_FILENAME "+
+	return cc.Flag("filename", "Name of file storing the secrets. This may also be a URL selecting a "+
+		"storage backend: file://secrets.yml, dir://secrets/ (one file per secret), or "+
+		"s3://bucket/secrets.yml. If the environment variable BISCUIT_FILENAME "+
 		"is set, it will be used as the default value.").
 		PlaceHolder("FILE").
 		Envar("BISCUIT_FILENAME").
@@ -183,3 +186,10 @@ func AwsRegionPriorityFlag(cc *kingpin.CmdClause) *[]string {
 func SecretNameArg(cc *kingpin.CmdClause) *string {
 	return cc.Arg("name", "Name of the secret to read.").Required().String()
 }
+
+// ParallelismFlag defines a flag for the number of secrets to process concurrently.
+func ParallelismFlag(cc *kingpin.CmdClause) *int {
+	return cc.Flag("parallelism", "Number of secrets to process concurrently.").
+		Default("8").
+		Int()
+}
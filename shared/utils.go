@@ -2,6 +2,7 @@ package shared
 
 import (
 	"log"
+	"os"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -17,9 +18,14 @@ func MustYaml(i interface{}) string {
 	return string(bytes)
 }
 
+// EndpointEnv names the environment variable that overrides the endpoint of every AWS service, ex:
+// http://127.0.0.1:4566. This is intended for testing against a local emulator.
+const EndpointEnv = "BISCUIT_AWS_ENDPOINT"
+
 func GetNewSession() *session.Session {
 	session, err := session.NewSessionWithOptions(session.Options{
 		SharedConfigState: session.SharedConfigEnable, // Must be set to enable
+		Config:            *endpointConfig(aws.NewConfig()),
 	})
 	if err != nil {
 		log.Fatal("error:", err)
@@ -30,10 +36,17 @@ func GetNewSession() *session.Session {
 func GetNewSessionWithRegion(region string) *session.Session {
 	session, err := session.NewSessionWithOptions(session.Options{
 		SharedConfigState: session.SharedConfigEnable, // Must be set to enable
-		Config:            *aws.NewConfig().WithRegion(region),
+		Config:            *endpointConfig(aws.NewConfig().WithRegion(region)),
 	})
 	if err != nil {
 		log.Fatal("error:", err)
 	}
 	return session
 }
+
+func endpointConfig(config *aws.Config) *aws.Config {
+	if endpoint := os.Getenv(EndpointEnv); len(endpoint) > 0 {
+		return config.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+	return config
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// snapshot identifies the contents of a file at the time it was read.
+type snapshot struct {
+	exists bool
+	sum    [sha256.Size]byte
+	mode   os.FileMode
+}
+
+func takeSnapshot(filename string) (snapshot, []byte, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return snapshot{mode: 0644}, nil, nil
+	}
+	if err != nil {
+		return snapshot{}, nil, err
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return snapshot{}, nil, err
+	}
+	return snapshot{exists: true, sum: sha256.Sum256(contents), mode: info.Mode().Perm()}, contents, nil
+}
+
+func (s snapshot) matches(other snapshot) bool {
+	return s.exists == other.exists && bytes.Equal(s.sum[:], other.sum[:])
+}
+
+// version returns the Version of the contents the snapshot was taken of.
+func (s snapshot) version() Version {
+	if !s.exists {
+		return ""
+	}
+	return Version("sha256:" + hex.EncodeToString(s.sum[:]))
+}
+
+// contentVersion returns the Version of a file with contents.
+func contentVersion(contents []byte) Version {
+	return snapshot{exists: true, sum: sha256.Sum256(contents)}.version()
+}
+
+// update performs a locked read-modify-write of the file. mutate is called with the current entries and may
+// modify them in place; if it returns an error, nothing is written. If version is not nil, the update fails
+// with ErrConflict unless the file still has that version.
+func (f FileStore) update(version *Version, mutate func(entries EntryMap) error) error {
+	unlock, err := lockFile(string(f) + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	before, contents, err := takeSnapshot(string(f))
+	if err != nil {
+		return err
+	}
+	if version != nil && before.version() != *version {
+		return fmt.Errorf("%s: %w", string(f), ErrConflict)
+	}
+	entries := make(EntryMap)
+	if err := yaml.Unmarshal(contents, entries); err != nil {
+		return err
+	}
+	if err := mutate(entries); err != nil {
+		return err
+	}
+	return f.compareAndSwap(before, entries)
+}
+
+// compareAndSwap replaces the file with entries if and only if its contents still match the snapshot.
+func (f FileStore) compareAndSwap(before snapshot, entries EntryMap) error {
+	output, err := yaml.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(string(f), output, before.mode, func() error {
+		current, _, err := takeSnapshot(string(f))
+		if err != nil {
+			return err
+		}
+		if !before.matches(current) {
+			return fmt.Errorf("%s: %w", string(f), ErrConflict)
+		}
+		return nil
+	})
+}
+
+// writeAtomic replaces filename with output by writing a temporary file in the same directory, flushing it
+// to disk and renaming it over filename, so that readers see either the old or the new contents. check is
+// called just before the rename; if it returns an error, filename is left untouched.
+func writeAtomic(filename string, output []byte, mode os.FileMode, check func() error) error {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	temp, err := ioutil.TempFile(dir, "."+base+".*.tmp")
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			os.Remove(temp.Name())
+		}
+	}()
+	if _, err := temp.Write(output); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Chmod(mode); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	if err := check(); err != nil {
+		return err
+	}
+	if err := os.Rename(temp.Name(), filename); err != nil {
+		return err
+	}
+	committed = true
+	return syncDir(dir)
+}
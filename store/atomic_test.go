@@ -0,0 +1,86 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_concurrentPuts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	store := NewFileStore(path.Join(dir, "secrets.yml"))
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, store.Put(fmt.Sprintf("k%d", i), ValueList{}))
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, entries, writers)
+
+	// Only the secrets file and its lock file should remain.
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestStore_conflictingWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	filename := path.Join(dir, "secrets.yml")
+	store := NewFileStore(filename)
+	assert.NoError(t, store.Put("k1", ValueList{}))
+
+	// Simulate a writer that does not respect the lock changing the file mid-update.
+	err = store.update(nil, func(entries EntryMap) error {
+		entries["k2"] = ValueList{}
+		return ioutil.WriteFile(filename, []byte("k3: []\n"), 0644)
+	})
+	assert.True(t, errors.Is(err, ErrConflict))
+
+	entries, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, EntryMap{"k3": ValueList{}}, entries)
+}
+
+func TestStore_PutIfVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	store := NewFileStore(path.Join(dir, "secrets.yml"))
+
+	_, version, err := store.GetAllVersion()
+	assert.True(t, IsProbablyNewStore(err))
+	assert.NoError(t, store.PutIfVersion(version, "k1", ValueList{}))
+	assert.True(t, errors.Is(store.PutIfVersion(version, "k1", ValueList{}), ErrConflict),
+		"the version of a missing file should not match once it exists")
+
+	_, version, err = store.GetVersion("k1")
+	assert.NoError(t, err)
+	// Another process writes between the read and the write.
+	assert.NoError(t, store.Put("k2", ValueList{}))
+	err = store.PutAllIfVersion(version, EntryMap{"k3": ValueList{}}, "k1")
+	assert.True(t, errors.Is(err, ErrConflict))
+
+	_, version, err = store.GetAllVersion()
+	assert.NoError(t, err)
+	assert.NoError(t, store.PutAllIfVersion(version, EntryMap{"k3": ValueList{}}, "k1"))
+	entries, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, EntryMap{"k2": ValueList{}, "k3": ValueList{}}, entries)
+}
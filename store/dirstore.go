@@ -0,0 +1,252 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	dirScheme    = "dir"
+	dirExtension = ".yml"
+	// dirLockName is the lock file within the directory. It is ignored by GetAll, as are all names beginning
+	// with a period.
+	dirLockName = ".lock"
+)
+
+func init() {
+	registry[dirScheme] = func(path string) (Store, error) {
+		return NewDirStore(path), nil
+	}
+}
+
+type errInvalidName struct {
+	name string
+}
+
+func (e *errInvalidName) Error() string {
+	return fmt.Sprintf("'%s' cannot be stored in a directory: names must not contain path separators "+
+		"or begin with a period", e.name)
+}
+
+// DirStore stores each secret in its own YAML file within a directory on local disk. This keeps
+// changes to unrelated secrets from conflicting with one another in source control.
+type DirStore string
+
+// NewDirStore constructs a DirStore for a specific directory.
+func NewDirStore(dir string) DirStore {
+	return DirStore(dir)
+}
+
+func (d DirStore) filename(name string) (string, error) {
+	if len(name) == 0 || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return "", &errInvalidName{name}
+	}
+	return filepath.Join(string(d), name+dirExtension), nil
+}
+
+// Get a value.
+func (d DirStore) Get(name string) (ValueList, error) {
+	values, _, err := d.GetVersion(name)
+	return values, err
+}
+
+// GetVersion gets a value and the version of its file.
+func (d DirStore) GetVersion(name string) (ValueList, Version, error) {
+	values, sum, err := d.read(name)
+	if err != nil {
+		return []Value{}, "", err
+	}
+	return values, dirVersion{name: sum}.encode(), nil
+}
+
+// read returns the values for name and the hash of the file they were read from.
+func (d DirStore) read(name string) (ValueList, string, error) {
+	filename, err := d.filename(name)
+	if err != nil {
+		return []Value{}, "", err
+	}
+	contents, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		if _, statErr := os.Stat(string(d)); statErr != nil {
+			return []Value{}, "", statErr
+		}
+		return []Value{}, "", ErrNameNotFound
+	}
+	if err != nil {
+		return []Value{}, "", err
+	}
+	var values ValueList
+	return values, string(contentVersion(contents)), yaml.Unmarshal(contents, &values)
+}
+
+// Put a value.
+func (d DirStore) Put(name string, values ValueList) error {
+	return d.update(nil, EntryMap{name: values}, nil)
+}
+
+// PutIfVersion puts a value if its file has not changed since version was read.
+func (d DirStore) PutIfVersion(version Version, name string, values ValueList) error {
+	return d.update(&version, EntryMap{name: values}, nil)
+}
+
+// PutAll replaces the values for each of the names in entries.
+func (d DirStore) PutAll(entries EntryMap) error {
+	return d.update(nil, entries, nil)
+}
+
+// PutAllIfVersion replaces the values for each of the names in entries and removes each of the names in
+// deletes if none of their files has changed since version was read. A name that version does not record
+// must not exist.
+func (d DirStore) PutAllIfVersion(version Version, entries EntryMap, deletes ...string) error {
+	return d.update(&version, entries, deletes)
+}
+
+// GetAll returns all of the entries in the directory.
+func (d DirStore) GetAll() (EntryMap, error) {
+	entries, _, err := d.GetAllVersion()
+	return entries, err
+}
+
+// GetAllVersion returns all of the entries in the directory and the versions of their files.
+func (d DirStore) GetAllVersion() (EntryMap, Version, error) {
+	entries := make(EntryMap)
+	version := make(dirVersion)
+	files, err := ioutil.ReadDir(string(d))
+	if err != nil {
+		return entries, "", err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), dirExtension) || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		name := strings.TrimSuffix(file.Name(), dirExtension)
+		values, sum, err := d.read(name)
+		if err != nil {
+			return entries, "", fmt.Errorf("%s: %s", file.Name(), err)
+		}
+		entries[name] = values
+		version[name] = sum
+	}
+	return entries, version.encode(), nil
+}
+
+// Delete removes a value.
+func (d DirStore) Delete(name string) error {
+	return d.update(nil, nil, []string{name})
+}
+
+// update writes the files for entries and removes the files for deletes while holding a lock on the
+// directory. If version is not nil, it fails with ErrConflict unless each of those files is as version
+// recorded it.
+func (d DirStore) update(version *Version, entries EntryMap, deletes []string) error {
+	var names []string
+	for name := range entries {
+		names = append(names, name)
+	}
+	names = append(names, deletes...)
+	for _, name := range names {
+		if _, err := d.filename(name); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(string(d), 0755); err != nil {
+		return err
+	}
+	unlock, err := lockFile(filepath.Join(string(d), dirLockName))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	recorded := make(dirVersion)
+	if version != nil {
+		if recorded, err = decodeDirVersion(*version); err != nil {
+			return err
+		}
+	}
+	before := make(map[string]snapshot)
+	for _, name := range names {
+		filename, _ := d.filename(name)
+		snap, _, err := takeSnapshot(filename)
+		if err != nil {
+			return err
+		}
+		if version != nil && string(snap.version()) != recorded[name] {
+			return fmt.Errorf("%s: %w", filename, ErrConflict)
+		}
+		before[name] = snap
+	}
+	for _, name := range deletes {
+		if !before[name].exists {
+			return ErrNameNotFound
+		}
+	}
+
+	for name, values := range entries {
+		filename, _ := d.filename(name)
+		output, err := yaml.Marshal(values)
+		if err != nil {
+			return err
+		}
+		err = writeAtomic(filename, output, before[name].mode, func() error {
+			current, _, err := takeSnapshot(filename)
+			if err != nil {
+				return err
+			}
+			if !before[name].matches(current) {
+				return fmt.Errorf("%s: %w", filename, ErrConflict)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range deletes {
+		if _, present := entries[name]; present {
+			continue
+		}
+		filename, _ := d.filename(name)
+		if err := os.Remove(filename); err != nil {
+			return err
+		}
+	}
+	return syncDir(string(d))
+}
+
+// dirVersion maps the name of each secret that was read to the version of its file.
+type dirVersion map[string]string
+
+func (v dirVersion) encode() Version {
+	encoded, _ := json.Marshal(v)
+	return Version(encoded)
+}
+
+func decodeDirVersion(version Version) (dirVersion, error) {
+	decoded := make(dirVersion)
+	if len(version) == 0 {
+		return decoded, nil
+	}
+	if err := json.Unmarshal([]byte(version), &decoded); err != nil {
+		return nil, fmt.Errorf("invalid version for a directory store: %s", err)
+	}
+	return decoded, nil
+}
+
+// GetKeyIds returns the keys specified by the template entry.
+func (d DirStore) GetKeyIds() ([]Key, error) {
+	template, err := d.Get(KeyTemplateName)
+	if err == ErrNameNotFound {
+		return nil, errNoTemplateEntry
+	}
+	if err != nil {
+		return nil, err
+	}
+	return templateKeys(EntryMap{KeyTemplateName: template})
+}
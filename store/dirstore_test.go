@@ -0,0 +1,86 @@
+package store
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirStore_Lifecycle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestDirStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	store := NewDirStore(path.Join(dir, "secrets"))
+
+	_, err = store.GetAll()
+	assert.True(t, IsProbablyNewStore(err))
+
+	template := ValueList{{Key: Key{Algorithm: "secretbox", KeyID: "key_id", KeyManager: "testing"}}}
+	assert.NoError(t, store.Put(KeyTemplateName, template))
+	k1put := ValueList{{
+		Key:           Key{Algorithm: "secretbox", KeyID: "key_id", KeyManager: "testing"},
+		KeyCiphertext: "ciphertext",
+		Ciphertext:    "ciphertext",
+	}}
+	assert.NoError(t, store.Put("k1", k1put))
+
+	k1actual, err := store.Get("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, k1put, k1actual)
+	_, err = store.Get("k2")
+	assert.Equal(t, ErrNameNotFound, err)
+
+	entries, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, EntryMap{KeyTemplateName: template, "k1": k1put}, entries)
+
+	keys, err := store.GetKeyIds()
+	assert.NoError(t, err)
+	assert.Equal(t, []Key{template[0].Key}, keys)
+
+	assert.NoError(t, store.Delete("k1"))
+	assert.Equal(t, ErrNameNotFound, store.Delete("k1"))
+	entries, err = store.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestDirStore_invalidNames(t *testing.T) {
+	store := NewDirStore(os.TempDir())
+	for _, name := range []string{"", "../escape", "a/b", ".hidden"} {
+		assert.Error(t, store.Put(name, ValueList{}), name)
+	}
+}
+
+func TestDirStore_PutIfVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestDirStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	store := NewDirStore(path.Join(dir, "secrets"))
+	assert.NoError(t, store.Put("k1", ValueList{}))
+
+	_, version, err := store.GetAllVersion()
+	assert.NoError(t, err)
+	// Changes to other secrets do not conflict, but changes to the secrets being written do.
+	assert.NoError(t, store.Put("k2", ValueList{}))
+	assert.NoError(t, store.PutIfVersion(version, "k1", ValueList{{Key: Key{Algorithm: "none"}}}))
+	assert.True(t, errors.Is(store.PutIfVersion(version, "k1", ValueList{}), ErrConflict))
+	assert.True(t, errors.Is(store.PutAllIfVersion(version, EntryMap{"k2": ValueList{}}), ErrConflict),
+		"names that the version does not record should not exist")
+
+	_, version, err = store.GetVersion("k1")
+	assert.NoError(t, err)
+	assert.NoError(t, store.PutAllIfVersion(version, EntryMap{"k3": ValueList{}}, "k1"))
+	entries, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, EntryMap{"k2": ValueList{}, "k3": ValueList{}}, entries)
+
+	// Only the secrets and the lock file should remain.
+	files, err := ioutil.ReadDir(path.Join(dir, "secrets"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+}
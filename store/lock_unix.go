@@ -0,0 +1,36 @@
+//go:build !windows
+// +build !windows
+
+package store
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile acquires an exclusive advisory lock on filename, creating it if necessary. The returned function
+// releases the lock.
+func lockFile(filename string) (func(), error) {
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// syncDir flushes directory metadata (such as a rename) to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,34 @@
+//go:build windows
+// +build windows
+
+package store
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile acquires an exclusive lock on filename, creating it if necessary. The returned function releases
+// the lock.
+func lockFile(filename string) (func(), error) {
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	handle := windows.Handle(f.Fd())
+	overlapped := &windows.Overlapped{}
+	if err := windows.LockFileEx(handle, windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, overlapped); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		windows.UnlockFileEx(handle, 0, 1, 0, overlapped)
+		f.Close()
+	}, nil
+}
+
+// syncDir is a no-op on Windows, which does not support flushing directory handles.
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/primait/biscuit/shared"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	s3Scheme = "s3"
+)
+
+func init() {
+	registry[s3Scheme] = func(path string) (Store, error) {
+		return NewS3Store(path)
+	}
+}
+
+type errInvalidS3Location struct {
+	location string
+}
+
+func (e *errInvalidS3Location) Error() string {
+	return fmt.Sprintf("s3://%s: S3 locations must be of the form s3://bucket/key", e.location)
+}
+
+// S3Store stores an EntryMap in a YAML object in an S3 bucket.
+type S3Store struct {
+	bucket, key string
+}
+
+// NewS3Store constructs an S3Store from a bucket/key path.
+func NewS3Store(path string) (*S3Store, error) {
+	split := strings.SplitN(path, "/", 2)
+	if len(split) != 2 || len(split[0]) == 0 || len(split[1]) == 0 {
+		return nil, &errInvalidS3Location{path}
+	}
+	return &S3Store{bucket: split[0], key: split[1]}, nil
+}
+
+func (s *S3Store) String() string {
+	return s3Scheme + "://" + s.bucket + "/" + s.key
+}
+
+func (s *S3Store) client() *s3.S3 {
+	return s3.New(shared.GetNewSession())
+}
+
+// Get a value.
+func (s *S3Store) Get(name string) (ValueList, error) {
+	values, _, err := s.GetVersion(name)
+	return values, err
+}
+
+// GetVersion gets a value and the ETag of the object it was read from.
+func (s *S3Store) GetVersion(name string) (ValueList, Version, error) {
+	entries, version, err := s.GetAllVersion()
+	if err != nil {
+		return []Value{}, version, err
+	}
+	value, present := entries[name]
+	if !present {
+		return []Value{}, version, ErrNameNotFound
+	}
+	return value, version, nil
+}
+
+// Put a value.
+func (s *S3Store) Put(name string, values ValueList) error {
+	return s.update(nil, EntryMap{name: values}, nil)
+}
+
+// PutIfVersion puts a value if the object has not changed since version was read.
+func (s *S3Store) PutIfVersion(version Version, name string, values ValueList) error {
+	return s.update(&version, EntryMap{name: values}, nil)
+}
+
+// PutAll replaces the values for each of the names in entries.
+func (s *S3Store) PutAll(updates EntryMap) error {
+	return s.update(nil, updates, nil)
+}
+
+// PutAllIfVersion replaces the values for each of the names in entries and removes each of the names in
+// deletes if the object has not changed since version was read.
+func (s *S3Store) PutAllIfVersion(version Version, updates EntryMap, deletes ...string) error {
+	return s.update(&version, updates, deletes)
+}
+
+// Delete removes a value.
+func (s *S3Store) Delete(name string) error {
+	return s.update(nil, nil, []string{name})
+}
+
+// update reads the object, applies the changes and writes it back. If version is not nil, it fails with
+// ErrConflict unless the object still has that ETag. S3 offers no conditional writes through this SDK, so a
+// write by another process between the check and the upload is not detected.
+func (s *S3Store) update(version *Version, updates EntryMap, deletes []string) error {
+	entries, current, err := s.GetAllVersion()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if version != nil && current != *version {
+		return fmt.Errorf("%s: %w", s, ErrConflict)
+	}
+	if err := applyChanges(entries, updates, deletes); err != nil {
+		return err
+	}
+	return s.write(entries)
+}
+
+func (s *S3Store) write(entries EntryMap) error {
+	output, err := yaml.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	_, err = s.client().PutObject(&s3.PutObjectInput{
+		Bucket:               aws.String(s.bucket),
+		Key:                  aws.String(s.key),
+		Body:                 bytes.NewReader(output),
+		ContentType:          aws.String("application/x-yaml"),
+		ServerSideEncryption: aws.String(s3.ServerSideEncryptionAes256),
+	})
+	return err
+}
+
+// GetAll returns all of the entries in the object. If the object does not exist, the error will satisfy
+// os.IsNotExist.
+func (s *S3Store) GetAll() (EntryMap, error) {
+	entries, _, err := s.GetAllVersion()
+	return entries, err
+}
+
+// GetAllVersion returns all of the entries in the object and its ETag.
+func (s *S3Store) GetAllVersion() (EntryMap, Version, error) {
+	entries := make(EntryMap)
+	output, err := s.client().GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key),
+	})
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == s3.ErrCodeNoSuchKey {
+		return entries, "", &os.PathError{Op: "open", Path: s.String(), Err: os.ErrNotExist}
+	}
+	if err != nil {
+		return entries, "", err
+	}
+	defer output.Body.Close()
+	contents, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return entries, "", err
+	}
+	return entries, Version(aws.StringValue(output.ETag)), yaml.Unmarshal(contents, entries)
+}
+
+// GetKeyIds returns the keys specified by the template entry.
+func (s *S3Store) GetKeyIds() ([]Key, error) {
+	entries, err := s.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return templateKeys(entries)
+}
@@ -59,26 +59,25 @@ func (b sortable) Less(i, j int) bool {
 }
 
 func lessWithRegionOrdering(ordering map[string]int, left Value, right Value) bool {
-	nat := strings.Compare(left.KeyManager, right.KeyManager)
-	if nat < 0 {
-		return true
+	// Values in the preferred regions come first, ahead of values from other key managers such as age,
+	// which the user may not be able to decrypt.
+	leftPreference, rightPreference := regionPreference(ordering, left), regionPreference(ordering, right)
+	if leftPreference != rightPreference {
+		return leftPreference > rightPreference
 	}
-	if nat > 0 {
-		return false
-	}
-	if left.KeyManager != keymanager.KmsLabel {
-		return false
-	}
-	leftKey, err := keymanager.NewARN(left.KeyID)
-	if err != nil {
-		return false
+	return strings.Compare(left.KeyManager, right.KeyManager) < 0
+}
+
+// regionPreference returns the ordering of the region of a KMS value. Regions with a higher "ordering" will
+// move to the beginning of the list. Regions not in the ordering, and values from other key managers, get
+// the zero value and are placed towards the end of the list.
+func regionPreference(ordering map[string]int, value Value) int {
+	if value.KeyManager != keymanager.KmsLabel {
+		return 0
 	}
-	rightKey, err := keymanager.NewARN(right.KeyID)
+	key, err := keymanager.NewARN(value.KeyID)
 	if err != nil {
-		return false
+		return 0
 	}
-	// Regions with a higher "ordering" will move to the beginning of the
-	// list. Regions not in the ordering will get the zero value and be
-	// placed towards the end of the list.
-	return ordering[leftKey.Region] > ordering[rightKey.Region]
+	return ordering[key.Region]
 }
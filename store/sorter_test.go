@@ -31,6 +31,12 @@ var (
 			KeyManager: "kms",
 		},
 	}
+	ageValue = Value{
+		Key: Key{
+			KeyID:      "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p",
+			KeyManager: "age",
+		},
+	}
 	other = Value{
 		Key: Key{
 			KeyID:      "some other kind of key",
@@ -87,6 +93,11 @@ func TestSortByKmsRegion(t *testing.T) {
 			expected: ValueList{west1, east1b, other},
 			regions:  []string{"us-west-1", "us-east-1"},
 		},
+		{
+			input:    ValueList{ageValue, west1, west2, other},
+			expected: ValueList{west2, ageValue, west1, other},
+			regions:  []string{"us-west-2"},
+		},
 		{
 			input:    ValueList{west1, west2, east1, other},
 			expected: ValueList{west1, west2, east1, other},
@@ -3,8 +3,12 @@ package store
 import (
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -18,14 +22,110 @@ var (
 
 	// ErrNameNotFound is returned by Get if the named secret does not exist.
 	ErrNameNotFound = errors.New("name not found")
+
+	// ErrConflict is returned by Put, Delete and the IfVersion methods if the store was modified by another
+	// process after it was read. No changes are written when this happens, and the operation can be retried.
+	ErrConflict = errors.New("the store was modified by another process while this change was being " +
+		"made; no changes were written. Please try again")
+
+	registry = make(map[string]func(location string) (Store, error))
 )
 
+// Store represents a place where secrets are kept.
+type Store interface {
+	// Get returns the values for a single name.
+	Get(name string) (ValueList, error)
+	// GetVersion is Get, and also returns the version of the store that the values were read from.
+	GetVersion(name string) (ValueList, Version, error)
+	// Put replaces the values for a single name.
+	Put(name string, values ValueList) error
+	// PutIfVersion is Put, but fails with ErrConflict if the store has changed since version was read.
+	PutIfVersion(version Version, name string, values ValueList) error
+	// PutAll replaces the values for each of the names in entries in a single write. Names not present in
+	// entries are left untouched.
+	PutAll(entries EntryMap) error
+	// PutAllIfVersion is PutAll, and also removes each of the names in deletes in the same write. It fails
+	// with ErrConflict if the store has changed since version was read.
+	PutAllIfVersion(version Version, entries EntryMap, deletes ...string) error
+	// GetAll returns all of the entries in the store.
+	GetAll() (EntryMap, error)
+	// GetAllVersion is GetAll, and also returns the version of the store that the entries were read from.
+	GetAllVersion() (EntryMap, Version, error)
+	// Delete removes a name from the store.
+	Delete(name string) error
+	// GetKeyIds returns the keys specified by the template entry.
+	GetKeyIds() ([]Key, error)
+}
+
+// Version identifies the contents of a store at the time they were read, so that a change computed from them
+// is not written over a change that another process made in the meantime. Versions are opaque, and only
+// meaningful to the store that returned them. The version of a store that does not exist yet is empty.
+type Version string
+
+type errUnsupportedScheme struct {
+	scheme string
+}
+
+func (e *errUnsupportedScheme) Error() string {
+	return fmt.Sprintf("unsupported storage scheme '%s://' (options: %s)", e.scheme,
+		strings.Join(GetSchemes(), ", "))
+}
+
+// Open returns the Store identified by location. Locations may be plain filenames or URLs of the form
+// scheme://path, where the scheme selects the storage backend (ex: file://secrets.yml, dir://secrets/,
+// s3://bucket/secrets.yml).
+func Open(location string) (Store, error) {
+	scheme, path := splitLocation(location)
+	if constructor, present := registry[scheme]; present {
+		return constructor(path)
+	}
+	return nil, &errUnsupportedScheme{scheme}
+}
+
+// GetSchemes returns a list of registered storage schemes.
+func GetSchemes() []string {
+	var schemes []string
+	for k := range registry {
+		schemes = append(schemes, k)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// splitLocation splits a location into its scheme and path. Locations without a scheme are files.
+func splitLocation(location string) (string, string) {
+	if i := strings.Index(location, "://"); i > 0 {
+		return location[:i], location[i+3:]
+	}
+	return fileScheme, location
+}
+
+const fileScheme = "file"
+
+func init() {
+	registry[fileScheme] = func(path string) (Store, error) {
+		return NewFileStore(path), nil
+	}
+}
+
 // FileStore stores an EntryMap in a YAML file on local disk.
 type FileStore string
 
 // EntryMap represents the contents of the file.
 type EntryMap map[string]ValueList
 
+// SecretNames returns the sorted names of the secrets in e, excluding the key template.
+func (e EntryMap) SecretNames() []string {
+	var names []string
+	for name := range e {
+		if name != KeyTemplateName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // ValueList represents a list of Values.
 type ValueList []Value
 
@@ -47,46 +147,85 @@ func NewFileStore(filename string) FileStore {
 
 // Get a value.
 func (f FileStore) Get(name string) (ValueList, error) {
-	entries, err := f.GetAll()
+	values, _, err := f.GetVersion(name)
+	return values, err
+}
+
+// GetVersion gets a value and the version of the file it was read from.
+func (f FileStore) GetVersion(name string) (ValueList, Version, error) {
+	entries, version, err := f.GetAllVersion()
 	if err != nil {
-		return []Value{}, err
+		return []Value{}, version, err
 	}
 	value, present := entries[name]
 	if !present {
-		return []Value{}, ErrNameNotFound
+		return []Value{}, version, ErrNameNotFound
 	}
-	return value, nil
+	return value, version, nil
 }
 
-// Put a value.
+// Put a value. The file is locked for the duration of the read-modify-write, and the write fails with
+// ErrConflict if the file is changed by a process that does not respect the lock.
 func (f FileStore) Put(name string, values ValueList) error {
-	entries, err := f.GetAll()
-	if err != nil && !os.IsNotExist(err) {
-		return err
-	}
-	entries[name] = values
+	return f.PutAll(EntryMap{name: values})
+}
 
-	output, err := yaml.Marshal(entries)
-	if err != nil {
-		return err
-	}
+// PutIfVersion puts a value if the file has not changed since version was read.
+func (f FileStore) PutIfVersion(version Version, name string, values ValueList) error {
+	return f.PutAllIfVersion(version, EntryMap{name: values})
+}
+
+// PutAll replaces the values for each of the names in entries.
+func (f FileStore) PutAll(updates EntryMap) error {
+	return f.update(nil, func(entries EntryMap) error {
+		return applyChanges(entries, updates, nil)
+	})
+}
+
+// PutAllIfVersion replaces the values for each of the names in entries and removes each of the names in
+// deletes if the file has not changed since version was read.
+func (f FileStore) PutAllIfVersion(version Version, updates EntryMap, deletes ...string) error {
+	return f.update(&version, func(entries EntryMap) error {
+		return applyChanges(entries, updates, deletes)
+	})
+}
+
+// Delete removes a value.
+func (f FileStore) Delete(name string) error {
+	return f.update(nil, func(entries EntryMap) error {
+		return applyChanges(entries, nil, []string{name})
+	})
+}
 
-	// poor attempt at atomic file write
-	tempfile := string(f) + ".tmp"
-	if err := ioutil.WriteFile(tempfile, output, 0644); err != nil {
-		return err
+// applyChanges replaces and removes entries in place. It fails with ErrNameNotFound if a name in deletes is
+// not present.
+func applyChanges(entries EntryMap, updates EntryMap, deletes []string) error {
+	for _, name := range deletes {
+		if _, present := entries[name]; !present {
+			return ErrNameNotFound
+		}
+		delete(entries, name)
+	}
+	for name, values := range updates {
+		entries[name] = values
 	}
-	return os.Rename(tempfile, string(f))
+	return nil
 }
 
 // GetAll returns all of the entries in the file.
 func (f FileStore) GetAll() (EntryMap, error) {
+	entries, _, err := f.GetAllVersion()
+	return entries, err
+}
+
+// GetAllVersion returns all of the entries in the file and its version.
+func (f FileStore) GetAllVersion() (EntryMap, Version, error) {
 	contents, err := ioutil.ReadFile(string(f))
 	entries := make(EntryMap)
 	if err != nil {
-		return entries, err
+		return entries, "", err
 	}
-	return entries, yaml.Unmarshal(contents, entries)
+	return entries, contentVersion(contents), yaml.Unmarshal(contents, entries)
 }
 
 // IsProbablyNewStore returns true if an error returned by any of the methods in this package is likely to mean
@@ -101,6 +240,11 @@ func (f FileStore) GetKeyIds() ([]Key, error) {
 	if err != nil {
 		return nil, err
 	}
+	return templateKeys(entries)
+}
+
+// templateKeys extracts the keys from the template entry of entries.
+func templateKeys(entries EntryMap) ([]Key, error) {
 	template, present := entries[KeyTemplateName]
 	if !present {
 		return nil, errNoTemplateEntry
@@ -134,6 +278,34 @@ type Value struct {
 	KeyCiphertext string `yaml:"key_ciphertext,omitempty"`
 	// Ciphertext is the plaintext encrypted with the ephemeral key.
 	Ciphertext string `yaml:"ciphertext,omitempty"`
+
+	// Metadata describes the secret. It is neither encrypted nor authenticated, and it is absent from
+	// values written by older versions.
+	Metadata *Metadata `yaml:"metadata,omitempty"`
+}
+
+// Metadata is optional information about a secret. Each value of a secret carries a copy of it.
+type Metadata struct {
+	// UpdatedAt is when the plaintext was last written.
+	UpdatedAt time.Time `yaml:"updated_at,omitempty" json:"updated_at,omitempty"`
+	// UpdatedBy identifies who last wrote the plaintext, ex: an AWS caller ARN or a username.
+	UpdatedBy string `yaml:"updated_by,omitempty" json:"updated_by,omitempty"`
+	// Description is free-form text describing the secret.
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// Tags are free-form key/value pairs.
+	Tags map[string]string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	// ContentType hints at the format of the plaintext, ex: json, yaml or text.
+	ContentType string `yaml:"content_type,omitempty" json:"content_type,omitempty"`
+}
+
+// Metadata returns the metadata of the first value that has any, or nil.
+func (v ValueList) Metadata() *Metadata {
+	for _, value := range v {
+		if value.Metadata != nil {
+			return value.Metadata
+		}
+	}
+	return nil
 }
 
 // GetKeyCiphertext returns the base64-decoded encrypted key.
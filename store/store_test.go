@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"fmt"
 
@@ -93,3 +94,88 @@ func mustRemoveAll(dir string) {
 		fmt.Fprintf(os.Stderr, "failed to delete: %s\n", dir)
 	}
 }
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		location string
+		expected Store
+	}{
+		{"secrets.yml", FileStore("secrets.yml")},
+		{"/tmp/secrets.yml", FileStore("/tmp/secrets.yml")},
+		{"file://secrets.yml", FileStore("secrets.yml")},
+		{"file:///tmp/secrets.yml", FileStore("/tmp/secrets.yml")},
+		{"dir://secrets", DirStore("secrets")},
+		{"s3://bucket/path/secrets.yml", &S3Store{bucket: "bucket", key: "path/secrets.yml"}},
+	}
+	for _, tc := range tests {
+		actual, err := Open(tc.location)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, actual)
+	}
+
+	for _, invalid := range []string{"ftp://host/secrets.yml", "s3://bucket", "s3:///key"} {
+		_, err := Open(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestStore_Delete(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "TestStore")
+	defer mustRemove(tmpfile.Name())
+	assert.NoError(t, err)
+	store := NewFileStore(tmpfile.Name())
+	assert.NoError(t, store.Put("k1", ValueList{}))
+	assert.NoError(t, store.Put("k2", ValueList{}))
+	assert.NoError(t, store.Delete("k1"))
+	assert.Equal(t, ErrNameNotFound, store.Delete("k1"))
+	entries, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, EntryMap{"k2": ValueList{}}, entries)
+}
+
+func TestStore_PutAll(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "TestStore")
+	defer mustRemove(tmpfile.Name())
+	assert.NoError(t, err)
+	store := NewFileStore(tmpfile.Name())
+	assert.NoError(t, store.Put("k1", ValueList{}))
+	k2 := ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "k2"}}
+	k3 := ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "k3"}}
+	assert.NoError(t, store.PutAll(EntryMap{"k2": k2, "k3": k3}))
+	entries, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, EntryMap{"k1": ValueList{}, "k2": k2, "k3": k3}, entries)
+}
+
+func TestStore_Metadata(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "TestStore")
+	defer mustRemove(tmpfile.Name())
+	assert.NoError(t, err)
+	store := NewFileStore(tmpfile.Name())
+
+	// Files written before metadata existed have none.
+	assert.NoError(t, ioutil.WriteFile(tmpfile.Name(), []byte("k1:\n- algorithm: none\n  ciphertext: k1\n"),
+		0644))
+	values, err := store.Get("k1")
+	assert.NoError(t, err)
+	assert.Nil(t, values.Metadata())
+
+	metadata := &Metadata{
+		UpdatedAt:   time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC),
+		UpdatedBy:   "oreilly",
+		Description: "launch codes",
+		Tags:        map[string]string{"team": "ops"},
+	}
+	k2 := ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "k2"},
+		{Key: Key{Algorithm: "none"}, Ciphertext: "k2", Metadata: metadata}}
+	assert.NoError(t, store.Put("k2", k2))
+	values, err = store.Get("k2")
+	assert.NoError(t, err)
+	assert.Equal(t, metadata, values.Metadata())
+}
+
+func TestEntryMap_SecretNames(t *testing.T) {
+	entries := EntryMap{"b": nil, KeyTemplateName: nil, "a": nil}
+	assert.Equal(t, []string{"a", "b"}, entries.SecretNames())
+	assert.Empty(t, EntryMap{}.SecretNames())
+}
@@ -1,2 +0,0 @@
-#!/bin/bash
-biscuit --help 2>&1 | grep Commands:
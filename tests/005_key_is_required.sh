@@ -1,3 +0,0 @@
-#!/bin/bash -x
-biscuit put -f store.yaml password god
-[[ 1 == "$?" ]]
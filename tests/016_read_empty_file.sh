@@ -1,4 +0,0 @@
-#!/bin/bash -x
-touch empty.yaml
-biscuit get -f empty.yaml key
-[[ 1 == "$?" ]]
@@ -0,0 +1,1007 @@
+// Package tests contains end-to-end tests that run the biscuit binary against an emulated AWS.
+package tests
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/primait/biscuit/fakeaws"
+	"github.com/primait/biscuit/shared"
+	"github.com/primait/biscuit/store"
+)
+
+const (
+	region1 = "us-west-1"
+	region2 = "us-west-2"
+)
+
+var (
+	biscuitBinary string
+	endpoint      string
+	fake          *fakeaws.Server
+	arn1, arn2    string
+	key1          string
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	dir, err := ioutil.TempDir("", "biscuit-e2e")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	biscuitBinary = filepath.Join(dir, "biscuit")
+	if runtime.GOOS == "windows" {
+		biscuitBinary += ".exe"
+	}
+	build := exec.Command("go", "build", "-o", biscuitBinary, "..")
+	build.Stdout = os.Stdout
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to build biscuit: %s\n", err)
+		return 1
+	}
+
+	fake = fakeaws.New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	endpoint = server.URL
+	arn1 = fake.CreateKey(region1, "e2e")
+	arn2 = fake.CreateKey(region2, "e2e")
+	key1 = arn1[strings.LastIndex(arn1, "/")+1:]
+
+	return m.Run()
+}
+
+// env runs biscuit in a private working directory with credentials for the emulated AWS.
+type env struct {
+	t   *testing.T
+	dir string
+}
+
+func newEnv(t *testing.T) *env {
+	return &env{t: t, dir: t.TempDir()}
+}
+
+func (e *env) path(name string) string {
+	return filepath.Join(e.dir, name)
+}
+
+func (e *env) environ(overrides []string) []string {
+	var environ []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "AWS_") && !strings.HasPrefix(kv, "BISCUIT_") {
+			environ = append(environ, kv)
+		}
+	}
+	environ = append(environ,
+		"AWS_ACCESS_KEY_ID=AKIDBISCUITTESTING",
+		"AWS_SECRET_ACCESS_KEY=secret",
+		"AWS_REGION="+region1,
+		"AWS_CONFIG_FILE="+e.path("aws-config-does-not-exist"),
+		"AWS_SHARED_CREDENTIALS_FILE="+e.path("aws-credentials-do-not-exist"),
+		shared.EndpointEnv+"="+endpoint)
+	return append(environ, overrides...)
+}
+
+// runWithEnv runs biscuit with additional environment variables and returns stdout and stderr.
+func (e *env) runWithEnv(overrides []string, args ...string) (string, string, error) {
+	cmd := exec.Command(biscuitBinary, args...)
+	cmd.Dir = e.dir
+	cmd.Env = e.environ(overrides)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+func (e *env) run(args ...string) (string, string, error) {
+	return e.runWithEnv(nil, args...)
+}
+
+// mustRun runs biscuit and fails the test if it exits with an error.
+func (e *env) mustRun(args ...string) string {
+	e.t.Helper()
+	stdout, stderr, err := e.run(args...)
+	if err != nil {
+		e.t.Fatalf("biscuit %s: %s\n%s", strings.Join(args, " "), err, stderr)
+	}
+	return stdout
+}
+
+// mustFail runs biscuit and fails the test if it succeeds. Returns stderr.
+func (e *env) mustFail(args ...string) string {
+	e.t.Helper()
+	_, stderr, err := e.run(args...)
+	if err == nil {
+		e.t.Fatalf("biscuit %s: expected an error", strings.Join(args, " "))
+	}
+	return stderr
+}
+
+func (e *env) assertGet(overrides []string, expected string, args ...string) {
+	e.t.Helper()
+	stdout, stderr, err := e.runWithEnv(overrides, append([]string{"get"}, args...)...)
+	if err != nil {
+		e.t.Fatalf("biscuit get %s: %s\n%s", strings.Join(args, " "), err, stderr)
+	}
+	if stdout != expected {
+		e.t.Errorf("biscuit get %s: expected %q, got %q", strings.Join(args, " "), expected, stdout)
+	}
+}
+
+func (e *env) readFile(name string) string {
+	e.t.Helper()
+	contents, err := ioutil.ReadFile(e.path(name))
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return string(contents)
+}
+
+func (e *env) writeFile(name, contents string) {
+	e.t.Helper()
+	if err := ioutil.WriteFile(e.path(name), []byte(contents), 0644); err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+// values returns the values of a secret as stored in a file.
+func (e *env) values(filename, name string) store.ValueList {
+	e.t.Helper()
+	values, err := store.NewFileStore(e.path(filename)).Get(name)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return values
+}
+
+// copyReplacing copies a file, replacing each occurrence of old with new.
+func (e *env) copyReplacing(from, to, old, new string) {
+	e.t.Helper()
+	e.writeFile(to, strings.Replace(e.readFile(from), old, new, -1))
+}
+
+func TestHelp(t *testing.T) {
+	e := newEnv(t)
+	stdout, stderr, _ := e.run("--help")
+	if !strings.Contains(stdout+stderr, "Commands:") {
+		t.Errorf("expected a list of commands, got: %s%s", stdout, stderr)
+	}
+}
+
+func TestFullKeyArn(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1)
+	e.assertGet(nil, "god", "-f", "store.yaml", "password")
+}
+
+func TestKeyIDOnly(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", key1)
+	e.assertGet(nil, "god", "-f", "store.yaml", "password")
+}
+
+func TestFirstUseEstablishesTemplate(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", key1)
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly")
+	e.assertGet(nil, "god", "-f", "store.yaml", "password")
+	e.assertGet(nil, "oreilly", "-f", "store.yaml", "username")
+}
+
+func TestKeyIsRequired(t *testing.T) {
+	e := newEnv(t)
+	e.mustFail("put", "-f", "store.yaml", "password", "god")
+}
+
+func TestTemplateCanBeRemoved(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", key1)
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly")
+	e.copyReplacing("store.yaml", "store.yaml", store.KeyTemplateName, "_removed")
+	e.assertGet(nil, "god", "-f", "store.yaml", "password")
+	e.assertGet(nil, "oreilly", "-f", "store.yaml", "username")
+}
+
+func TestKeyIDNotFound(t *testing.T) {
+	e := newEnv(t)
+	stderr := e.mustFail("put", "-f", "store.yaml", "password", "god", "--key-id",
+		"c06320d9-aaaa-aaaa-aaaa-08263b0789d5")
+	if !strings.Contains(stderr, "NotFoundException") {
+		t.Errorf("expected NotFoundException, got: %s", stderr)
+	}
+}
+
+func TestMultipleKeys(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1+","+arn2)
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly")
+	e.assertGet(nil, "god", "-f", "store.yaml", "password")
+	e.assertGet(nil, "oreilly", "-f", "store.yaml", "username")
+}
+
+func TestMultipleRegionsOneFails(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1+","+arn2)
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly")
+
+	e.copyReplacing("store.yaml", "corrupt1.yaml", region1, "xxx")
+	e.assertGet(nil, "god", "-f", "corrupt1.yaml", "password")
+	e.assertGet(nil, "oreilly", "-f", "corrupt1.yaml", "username")
+
+	e.copyReplacing("store.yaml", "corrupt2.yaml", region2, "xxx")
+	e.assertGet(nil, "god", "-f", "corrupt2.yaml", "password")
+	e.assertGet(nil, "oreilly", "-f", "corrupt2.yaml", "username")
+}
+
+func TestPlaintext(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "-a", "none")
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly")
+	e.assertGet(nil, "god", "-f", "store.yaml", "password")
+	e.assertGet(nil, "oreilly", "-f", "store.yaml", "username")
+}
+
+func TestKmsThenPlaintext(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1)
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly")
+	e.mustRun("put", "-f", "store.yaml", "spice", "scary", "-a", "none")
+	e.assertGet(nil, "god", "-f", "store.yaml", "password")
+	e.assertGet(nil, "oreilly", "-f", "store.yaml", "username")
+	e.assertGet(nil, "scary", "-f", "store.yaml", "spice")
+}
+
+func TestPlaintextThenKms(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "-a", "none")
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly", "--key-id", arn1)
+	e.mustRun("put", "-f", "store.yaml", "spice", "scary", "--key-id", arn1)
+	e.assertGet(nil, "god", "-f", "store.yaml", "password")
+	e.assertGet(nil, "oreilly", "-f", "store.yaml", "username")
+	e.assertGet(nil, "scary", "-f", "store.yaml", "spice")
+}
+
+func TestPlaintextThenKmsAes(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "-a", "none")
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly", "--key-id", arn1+","+arn2, "-a", "aesgcm256")
+	e.mustRun("put", "-f", "store.yaml", "spice", "scary", "--key-id", arn2)
+	e.assertGet(nil, "god", "-f", "store.yaml", "password")
+	e.assertGet(nil, "oreilly", "-f", "store.yaml", "username")
+	e.assertGet(nil, "scary", "-f", "store.yaml", "spice")
+	contents := e.readFile("store.yaml")
+	// spice uses the default algorithm.
+	for _, algorithm := range []string{"aesgcm256\n", "secretbox\n", "none\n"} {
+		if !strings.Contains(contents, "algorithm: "+algorithm) {
+			t.Errorf("expected %s in:\n%s", algorithm, contents)
+		}
+	}
+}
+
+func TestCrossRegions(t *testing.T) {
+	e := newEnv(t)
+	inRegion1 := []string{"AWS_REGION=" + region1}
+	inRegion2 := []string{"AWS_REGION=" + region2}
+	if _, stderr, err := e.runWithEnv(inRegion2, "put", "-f", "store.yaml", "password", "r1",
+		"--key-id", arn1); err != nil {
+		t.Fatalf("%s\n%s", err, stderr)
+	}
+	if _, stderr, err := e.runWithEnv(inRegion1, "put", "-f", "store.yaml", "username", "r2",
+		"--key-id", arn2); err != nil {
+		t.Fatalf("%s\n%s", err, stderr)
+	}
+	e.assertGet(inRegion2, "r1", "-f", "store.yaml", "password")
+	e.assertGet(inRegion2, "r1", "-p", region1, "-f", "store.yaml", "password")
+	e.assertGet(inRegion1, "r1", "-p", region1, "-f", "store.yaml", "password")
+	e.assertGet(inRegion1, "r2", "-f", "store.yaml", "username")
+	e.assertGet(inRegion1, "r2", "-p", region2, "-f", "store.yaml", "username")
+	e.assertGet(inRegion2, "r2", "-p", region2, "-f", "store.yaml", "username")
+}
+
+func TestReadNonexistentFile(t *testing.T) {
+	e := newEnv(t)
+	stderr := e.mustFail("get", "-f", "404.yaml", "key")
+	if !strings.Contains(stderr, "no such file") {
+		t.Errorf("expected a missing file error, got: %s", stderr)
+	}
+}
+
+func TestReadEmptyFile(t *testing.T) {
+	e := newEnv(t)
+	e.writeFile("empty.yaml", "")
+	e.mustFail("get", "-f", "empty.yaml", "key")
+}
+
+func Test1MB(t *testing.T) {
+	e := newEnv(t)
+	expected := make([]byte, 1000*1024)
+	if _, err := rand.Read(expected); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(e.path("1mb.dat"), expected, 0644); err != nil {
+		t.Fatal(err)
+	}
+	e.mustRun("put", "-f", "store.yaml", "1mb-sb", "--from-file", "1mb.dat", "--key-id", arn1)
+	e.mustRun("put", "-f", "store.yaml", "1mb-aes", "--from-file", "1mb.dat", "-a", "aesgcm256")
+	e.mustRun("put", "-f", "store.yaml", "1mb-none", "--from-file", "1mb.dat", "-a", "none")
+	for _, name := range []string{"1mb-sb", "1mb-aes", "1mb-none"} {
+		e.mustRun("get", "-f", "store.yaml", name, "-o", name+"-file.dat")
+		if e.readFile(name+"-file.dat") != string(expected) {
+			t.Errorf("%s: -o output does not match", name)
+		}
+		if e.mustRun("get", "-f", "store.yaml", name) != string(expected) {
+			t.Errorf("%s: stdout does not match", name)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	e := newEnv(t)
+	single, err := filepath.Abs("single.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Fields(e.mustRun("list", "-f", single)); len(names) != 1 {
+		t.Errorf("expected one name, got %q", names)
+	}
+	e.assertGet(nil, "bar", "-f", single, "name1")
+}
+
+func TestMultipleRegionsBothFail(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1+","+arn2)
+	e.copyReplacing("store.yaml", "corrupt1.yaml", region1, "xxx")
+	e.copyReplacing("corrupt1.yaml", "corrupt1.yaml", region2, "xxx")
+	e.mustFail("get", "-f", "corrupt1.yaml", "password")
+}
+
+func TestExport(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "-a", "none")
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly", "--key-id", arn1+","+arn2, "-a", "aesgcm256")
+	e.mustRun("put", "-f", "store.yaml", "spice", "scary", "--key-id", arn2)
+	exported := e.mustRun("export", "-f", "store.yaml")
+	if lines := strings.Split(strings.TrimSpace(exported), "\n"); len(lines) != 3 {
+		t.Errorf("expected 3 lines, got %q", lines)
+	}
+	for _, expected := range []string{": god", ": oreilly", ": scary"} {
+		if !strings.Contains(exported, expected) {
+			t.Errorf("expected %q in:\n%s", expected, exported)
+		}
+	}
+
+	// Secrets that cannot be decrypted are left out and listed in the error.
+	e.copyReplacing("store.yaml", "corrupt.yaml", region2, "xxx")
+	stdout, stderr, err := e.run("export", "-f", "corrupt.yaml", "--parallelism", "2")
+	if err == nil {
+		t.Errorf("expected export to fail")
+	}
+	if stdout != "password: god\nusername: oreilly\n" {
+		t.Errorf("unexpected export:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, "1 of 3 secrets could not be decrypted") || !strings.Contains(stderr, "  spice: ") {
+		t.Errorf("expected the error to list spice:\n%s", stderr)
+	}
+
+	e.writeFile("exported.sh", "")
+	e.mustRun("export", "-f", "store.yaml", "--format", "shell", "--exclude", "s*", "-o", "exported.sh")
+	if exported := e.readFile("exported.sh"); exported != "export PASSWORD='god'\nexport USERNAME='oreilly'\n" {
+		t.Errorf("unexpected shell export:\n%s", exported)
+	}
+	if info, err := os.Stat(e.path("exported.sh")); err != nil {
+		t.Error(err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %s", info.Mode())
+	}
+}
+
+func TestDelete(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1)
+	e.mustRun("put", "-f", "store.yaml", "spice", "scary")
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly")
+
+	e.mustFail("delete", "-f", "store.yaml", store.KeyTemplateName)
+	// Nothing is deleted if any of the names does not exist.
+	e.mustFail("delete", "-f", "store.yaml", "spice", "nonexistent")
+	e.assertGet(nil, "scary", "-f", "store.yaml", "spice")
+
+	e.mustRun("delete", "-f", "store.yaml", "spice", "username")
+	e.mustFail("get", "-f", "store.yaml", "spice")
+	e.mustFail("get", "-f", "store.yaml", "username")
+	e.assertGet(nil, "god", "-f", "store.yaml", "password")
+	e.mustRun("put", "-f", "store.yaml", "spice", "mild")
+}
+
+func TestRename(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1+","+arn2)
+	e.mustRun("put", "-f", "store.yaml", "spice", "scary")
+
+	e.mustFail("rename", "-f", "store.yaml", store.KeyTemplateName, "keys")
+	e.mustFail("rename", "-f", "store.yaml", "password", store.KeyTemplateName)
+	e.mustFail("rename", "-f", "store.yaml", "nonexistent", "other")
+	e.mustFail("rename", "-f", "store.yaml", "password", "spice")
+	e.assertGet(nil, "scary", "-f", "store.yaml", "spice")
+
+	e.mustRun("rename", "-f", "store.yaml", "password", "pw")
+	e.mustFail("get", "-f", "store.yaml", "password")
+	if values := e.values("store.yaml", "pw"); len(values) != 2 || values[0].KeyID != arn1 || values[1].KeyID != arn2 {
+		t.Errorf("expected the values to keep their order, got %v", values)
+	}
+	e.assertGet(nil, "god", "-f", "store.yaml", "pw")
+	e.assertGet([]string{"AWS_REGION=" + region2}, "god", "-f", "store.yaml", "pw")
+
+	// The values are encrypted under the new name, so they do not decrypt under the old one.
+	e.copyReplacing("store.yaml", "renamed-back.yaml", "pw:", "password:")
+	e.mustFail("get", "-f", "renamed-back.yaml", "password")
+
+	e.mustRun("rename", "-f", "store.yaml", "pw", "spice", "--overwrite")
+	e.assertGet(nil, "god", "-f", "store.yaml", "spice")
+	e.mustFail("get", "-f", "store.yaml", "pw")
+}
+
+func TestRekey(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1)
+	template := e.values("store.yaml", store.KeyTemplateName)
+	template[0].KeyID = arn2
+	if err := store.NewFileStore(e.path("store.yaml")).Put(store.KeyTemplateName, template); err != nil {
+		t.Fatal(err)
+	}
+
+	e.mustRun("rekey", "-f", "store.yaml")
+	if values := e.values("store.yaml", "password"); len(values) != 2 {
+		t.Errorf("expected rekey to add a value and keep the stale one, got %d values", len(values))
+	}
+	e.mustRun("rekey", "-f", "store.yaml", "--prune")
+	values := e.values("store.yaml", "password")
+	if len(values) != 1 || values[0].KeyID != arn2 {
+		t.Errorf("expected a single value under %s, got %v", arn2, values)
+	}
+	if report := e.mustRun("rekey", "-f", "store.yaml"); report != "password: up to date\n" {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+
+	// The old key is no longer needed.
+	e.copyReplacing("store.yaml", "without-old.yaml", region1, "xxx")
+	e.assertGet(nil, "god", "-f", "without-old.yaml", "password")
+}
+
+func TestRotate(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1+","+arn2)
+	e.mustRun("put", "-f", "store.yaml", "spice", "scary")
+	before := e.readFile("store.yaml")
+
+	dryRun := e.mustRun("rotate", "-f", "store.yaml", "--dry-run", "--algorithm", "aesgcm256")
+	if !strings.Contains(dryRun, "password: 2 values (kms/") || !strings.Contains(dryRun,
+		"-> 2 values (kms/aesgcm256)") {
+		t.Errorf("unexpected dry run:\n%s", dryRun)
+	}
+	if e.readFile("store.yaml") != before {
+		t.Errorf("expected --dry-run to leave the file unchanged")
+	}
+
+	password, spice := e.values("store.yaml", "password"), e.values("store.yaml", "spice")
+	e.mustRun("rotate", "-f", "store.yaml", "password")
+	for i, value := range e.values("store.yaml", "password") {
+		if value.KeyCiphertext == password[i].KeyCiphertext || value.Ciphertext == password[i].Ciphertext {
+			t.Errorf("expected rotation to replace the data key of %s", value.KeyID)
+		}
+	}
+	if rotated := e.values("store.yaml", "spice"); rotated[0].KeyCiphertext != spice[0].KeyCiphertext {
+		t.Errorf("expected rotating password to leave spice unchanged")
+	}
+	e.assertGet(nil, "god", "-f", "store.yaml", "password")
+	e.assertGet([]string{"AWS_REGION=" + region2}, "god", "-f", "store.yaml", "password")
+
+	e.mustRun("rotate", "-f", "store.yaml", "--algorithm", "aesgcm256")
+	for _, name := range []string{"password", "spice"} {
+		for _, value := range e.values("store.yaml", name) {
+			if value.Algorithm != "aesgcm256" {
+				t.Errorf("expected %s to use aesgcm256, got %s", name, value.Algorithm)
+			}
+		}
+	}
+	e.assertGet(nil, "god", "-f", "store.yaml", "password")
+	e.assertGet(nil, "scary", "-f", "store.yaml", "spice")
+}
+
+func TestMixedTemplate(t *testing.T) {
+	e := newEnv(t)
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.writeFile("identities", identity.String()+"\n")
+	withAge := []string{"BISCUIT_AGE_IDENTITIES=" + e.path("identities")}
+	withoutKms := append([]string{shared.EndpointEnv + "=http://127.0.0.1:1"}, withAge...)
+
+	// The key manager of each key ID is chosen by its format.
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1+","+identity.Recipient().String())
+	e.mustRun("put", "-f", "store.yaml", "spice", "scary")
+	for _, name := range []string{"password", "spice"} {
+		var managers []string
+		for _, value := range e.values("store.yaml", name) {
+			managers = append(managers, value.KeyManager)
+		}
+		sort.Strings(managers)
+		if strings.Join(managers, ",") != "age,kms" {
+			t.Errorf("%s: expected kms and age values, got %s", name, managers)
+		}
+	}
+
+	e.assertGet(withoutKms, "scary", "-f", "store.yaml", "--call-timeout", "1s", "spice")
+	// Users without age identities read the KMS value without warnings about the age value.
+	stdout, stderr, err := e.run("get", "-f", "store.yaml", "password")
+	if err != nil || stdout != "god" || len(stderr) > 0 {
+		t.Errorf("expected god without warnings, got %q: %v\n%s", stdout, err, stderr)
+	}
+}
+
+func TestKmsInit(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("kms", "init", "-r", "us-east-1,eu-west-1", "-l", "e2e", "-f", "store.yaml")
+	e.mustRun("put", "-f", "store.yaml", "password", "god")
+	e.assertGet([]string{"AWS_REGION=eu-west-1"}, "god", "-f", "store.yaml", "password")
+	e.assertGet(nil, "god", "-p", "eu-west-1", "-f", "store.yaml", "password")
+
+	e.mustRun("kms", "grants", "create", "-f", "store.yaml", "-g", "role/webserver", "password")
+	if grants := e.mustRun("kms", "grants", "list", "-f", "store.yaml", "password"); !strings.Contains(grants,
+		"role/webserver") {
+		t.Errorf("expected a grant for role/webserver, got:\n%s", grants)
+	}
+	// Batch data keys are not bound to SecretName, so keys with grants restricted to one secret are not batched.
+	e.writeFile("import.env", "a=1\nb=2\n")
+	stderr := e.mustFail("import", "-f", "store.yaml", "--reuse-data-key", "2", "import.env")
+	if !strings.Contains(stderr, "restricts access by SecretName") {
+		t.Errorf("expected batching to be refused, got:\n%s", stderr)
+	}
+	e.mustRun("import", "-f", "store.yaml", "import.env")
+	e.assertGet(nil, "2", "-f", "store.yaml", "b")
+
+	e.mustRun("kms", "deprovision", "-r", "us-east-1,eu-west-1", "-l", "e2e", "--destructive")
+	e.mustFail("get", "-f", "store.yaml", "password")
+}
+
+func TestExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "db-password", "god", "--key-id", arn1)
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly")
+	stdout := e.mustRun("exec", "-f", "store.yaml", "--prefix", "APP_", "--only", "db-*", "--",
+		"sh", "-c", `echo "$APP_DB_PASSWORD:$APP_USERNAME"`)
+	if stdout != "god:\n" {
+		t.Errorf("expected %q, got %q", "god:\n", stdout)
+	}
+
+	// Patterns may be separated by commas, and a pattern that matches nothing is an error.
+	stdout = e.mustRun("exec", "-f", "store.yaml", "--only", "db-password,username", "--",
+		"sh", "-c", `echo "$DB_PASSWORD:$USERNAME"`)
+	if stdout != "god:oreilly\n" {
+		t.Errorf("expected %q, got %q", "god:oreilly\n", stdout)
+	}
+	stderr := e.mustFail("exec", "-f", "store.yaml", "--only", "zzz", "--", "sh", "-c", "echo ran")
+	if !strings.Contains(stderr, "no secret matches") {
+		t.Errorf("expected a no-match error, got %q", stderr)
+	}
+
+	// Secrets replace variables of the same name in biscuit's environment.
+	stdout, stderr, err := e.runWithEnv([]string{"USERNAME=stale"}, "exec", "-f", "store.yaml",
+		"--exclude", "db-*", "--", "sh", "-c", `echo "$DB_PASSWORD:$USERNAME"`)
+	if err != nil || stdout != ":oreilly\n" {
+		t.Errorf("expected %q, got %q: %v\n%s", ":oreilly\n", stdout, err, stderr)
+	}
+
+	_, _, err = e.run("exec", "-f", "store.yaml", "--", "sh", "-c", "exit 3")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 {
+		t.Errorf("expected exit status 3, got %v", err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1+","+arn2)
+	e.writeFile("import.env", "password=new\nUSERNAME=\"oreilly\"\n")
+	e.mustFail("import", "-f", "store.yaml", "import.env")
+
+	e.mustRun("import", "-f", "store.yaml", "--skip-existing", "import.env")
+	e.assertGet(nil, "god", "-f", "store.yaml", "password")
+	e.assertGet(nil, "oreilly", "-f", "store.yaml", "USERNAME")
+
+	e.writeFile("import.json", `{"password": "new", "spice": "scary"}`)
+	e.mustRun("import", "-f", "store.yaml", "--overwrite", "import.json")
+	e.assertGet(nil, "new", "-f", "store.yaml", "password")
+	e.assertGet([]string{"AWS_REGION=" + region2}, "scary", "-f", "store.yaml", "spice")
+}
+
+func TestDataKeyReuse(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1)
+	e.writeFile("import.env", "a=1\nb=2\nc=3\nd=4\ne=5\n")
+	generated := fake.KmsCalls("GenerateDataKey")
+	e.mustRun("import", "-f", "store.yaml", "--reuse-data-key", "3", "import.env")
+	if calls := fake.KmsCalls("GenerateDataKey") - generated; calls != 2 {
+		t.Errorf("expected 2 data keys for 5 secrets, got %d", calls)
+	}
+	e.assertGet(nil, "3", "-f", "store.yaml", "c")
+
+	expected := "a: \"1\"\nb: \"2\"\nc: \"3\"\nd: \"4\"\ne: \"5\"\npassword: god\n"
+	decrypts := fake.KmsCalls("Decrypt")
+	if exported := e.mustRun("--data-key-max-age", "1m", "export", "-f", "store.yaml"); exported != expected {
+		t.Errorf("unexpected export:\n%s", exported)
+	}
+	if calls := fake.KmsCalls("Decrypt") - decrypts; calls != 3 {
+		t.Errorf("expected one Decrypt per data key, got %d", calls)
+	}
+	decrypts = fake.KmsCalls("Decrypt")
+	if exported := e.mustRun("export", "-f", "store.yaml"); exported != expected {
+		t.Errorf("unexpected export:\n%s", exported)
+	}
+	if calls := fake.KmsCalls("Decrypt") - decrypts; calls != 6 {
+		t.Errorf("expected one Decrypt per secret without the cache, got %d", calls)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1+","+arn2)
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly")
+	e.writeFile("one.sh", "printf 'devil\\n' > \"$1\"\n")
+	e.writeFile("all.sh", "sed -e 's/hacker/nobody/' \"$1\" > \"$1.new\" && "+
+		"echo 'spice: scary' >> \"$1.new\" && mv \"$1.new\" \"$1\"\n")
+
+	editor := func(script string) []string {
+		return []string{"VISUAL=", "EDITOR=sh " + e.path(script)}
+	}
+	if _, stderr, err := e.runWithEnv(editor("one.sh"), "edit", "-f", "store.yaml", "password"); err != nil {
+		t.Fatalf("biscuit edit: %s\n%s", err, stderr)
+	}
+	e.assertGet(nil, "devil", "-f", "store.yaml", "password")
+	e.assertGet([]string{"AWS_REGION=" + region2}, "devil", "-f", "store.yaml", "password")
+	_, _, err := e.runWithEnv(editor("one.sh"), "edit", "-f", "store.yaml", "password")
+	if err == nil {
+		t.Errorf("expected an unchanged value to be an error")
+	}
+
+	// An editor whose path contains a space is run as is, and may also be quoted with arguments.
+	e.writeFile("my editor.sh", "#!/bin/sh\n"+
+		"if [ $# -eq 1 ]; then printf 'spaced\\n' > \"$1\"; else printf '%s\\n' \"$1\" > \"$2\"; fi\n")
+	if err := os.Chmod(e.path("my editor.sh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	env := []string{"VISUAL=", "EDITOR=" + e.path("my editor.sh")}
+	if _, stderr, err := e.runWithEnv(env, "edit", "-f", "store.yaml", "username"); err != nil {
+		t.Fatalf("biscuit edit with a path containing a space: %s\n%s", err, stderr)
+	}
+	e.assertGet(nil, "spaced", "-f", "store.yaml", "username")
+	env = []string{"VISUAL='" + e.path("my editor.sh") + "' hacker"}
+	if _, stderr, err := e.runWithEnv(env, "edit", "-f", "store.yaml", "username"); err != nil {
+		t.Fatalf("biscuit edit with a quoted path: %s\n%s", err, stderr)
+	}
+	e.assertGet(nil, "hacker", "-f", "store.yaml", "username")
+
+	stdout, stderr, err := e.runWithEnv(editor("all.sh"), "edit", "-f", "store.yaml", "--all")
+	if err != nil {
+		t.Fatalf("biscuit edit --all: %s\n%s", err, stderr)
+	}
+	if strings.Contains(stdout, "password") {
+		t.Errorf("expected only changed secrets to be re-encrypted, got %q", stdout)
+	}
+	e.assertGet(nil, "devil", "-f", "store.yaml", "password")
+	e.assertGet(nil, "nobody", "-f", "store.yaml", "username")
+	e.assertGet([]string{"AWS_REGION=" + region2}, "scary", "-f", "store.yaml", "spice")
+}
+
+func TestRender(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1)
+	e.writeFile("database.yml.tmpl", "password: {{ secret \"password\" | json }}\n")
+	e.mustRun("render", "-f", "store.yaml", "-o", "database.yml", "database.yml.tmpl")
+	if contents := e.readFile("database.yml"); contents != "password: \"god\"\n" {
+		t.Errorf("expected %q, got %q", "password: \"god\"\n", contents)
+	}
+	if info, err := os.Stat(e.path("database.yml")); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %s", info.Mode().Perm())
+	}
+
+	if runtime.GOOS != "windows" {
+		// Redirecting stdout to a file that others can read is allowed, but warned about.
+		redirected, err := os.OpenFile(e.path("shared.yml"), os.O_WRONLY|os.O_CREATE, 0644)
+		if err == nil {
+			err = redirected.Chmod(0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer redirected.Close()
+		cmd := exec.Command(biscuitBinary, "render", "-f", "store.yaml", "database.yml.tmpl")
+		cmd.Dir = e.dir
+		cmd.Env = e.environ(nil)
+		var stderr bytes.Buffer
+		cmd.Stdout = redirected
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil || !strings.Contains(stderr.String(), "use --output") {
+			t.Errorf("expected a warning about shared stdout: %v\n%s", err, stderr.String())
+		}
+	}
+
+	e.writeFile("missing.tmpl", "{{ secret \"missing\" }}\n")
+	e.mustFail("render", "-f", "store.yaml", "missing.tmpl")
+}
+
+func TestMetadata(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1,
+		"--description", "launch codes", "--tag", "team=ops")
+	e.mustRun("put", "-f", "store.yaml", "password", "devil")
+	e.mustRun("rename", "-f", "store.yaml", "password", "launch-codes")
+
+	stdout := e.mustRun("list", "-f", "store.yaml", "--long")
+	if !strings.Contains(stdout, "launch-codes") || !strings.Contains(stdout, fake.CallerArn()) ||
+		!strings.Contains(stdout, "launch codes") || !strings.Contains(stdout, "team=ops") {
+		t.Errorf("expected the metadata to be listed, got %q", stdout)
+	}
+
+	stdout = e.mustRun("export", "-f", "store.yaml", "--format", "json", "--metadata")
+	if !strings.Contains(stdout, `"description": "launch codes"`) {
+		t.Errorf("expected the metadata to be exported, got %q", stdout)
+	}
+	e.mustFail("export", "-f", "store.yaml", "--format", "dotenv", "--metadata")
+
+	// Secrets written by older versions have no metadata.
+	e.writeFile("old.yaml", "password:\n- algorithm: none\n  ciphertext: Z29k\n")
+	e.assertGet(nil, "god", "-f", "old.yaml", "password")
+	if stdout := e.mustRun("list", "-f", "old.yaml", "-l"); !strings.Contains(stdout, "password") {
+		t.Errorf("expected password to be listed, got %q", stdout)
+	}
+}
+
+func TestList(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1+","+arn2)
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly", "--key-id", arn1)
+	e.mustRun("put", "-f", "store.yaml", "api-key", "xyzzy")
+	if stdout := e.mustRun("list", "-f", "store.yaml"); stdout != "api-key\npassword\nusername\n" {
+		t.Errorf("expected sorted names, got %q", stdout)
+	}
+	if stdout := e.mustRun("list", "-f", "store.yaml", "--missing-region", region2); stdout != "username\n" {
+		t.Errorf("expected only username to be missing from %s, got %q", region2, stdout)
+	}
+
+	var listings []struct {
+		Name            string
+		Regions         []string
+		MatchesTemplate bool `json:"matches_template"`
+	}
+	if err := json.Unmarshal([]byte(e.mustRun("list", "-f", "store.yaml", "--format", "json")),
+		&listings); err != nil {
+		t.Fatal(err)
+	}
+	if len(listings) != 3 || listings[2].Name != "username" || listings[2].MatchesTemplate ||
+		len(listings[1].Regions) != 2 || !listings[1].MatchesTemplate {
+		t.Errorf("unexpected listing: %+v", listings)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1+","+arn2, "-a", "aesgcm256-v2")
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly")
+	if stdout := e.mustRun("verify", "-f", "store.yaml"); stdout != "password: ok (2 values)\n"+
+		"username: ok (2 values)\n" {
+		t.Errorf("unexpected report: %q", stdout)
+	}
+	// Values encrypted with the default algorithm are not bound to their names.
+	e.mustRun("put", "-f", "default.yaml", "password", "god", "--key-id", arn1)
+	if stdout := e.mustRun("verify", "-f", "default.yaml"); !strings.Contains(stdout,
+		"secretbox does not bind the value to its name") {
+		t.Errorf("expected the default algorithm to be reported, got %q", stdout)
+	}
+
+	// get still succeeds when one region is broken, but verify does not.
+	e.copyReplacing("store.yaml", "corrupt.yaml", region2, "xxx")
+	e.assertGet(nil, "god", "-f", "corrupt.yaml", "password")
+	stdout, _, err := e.run("verify", "-f", "corrupt.yaml", "--format", "json")
+	if err == nil {
+		t.Errorf("expected verify to fail")
+	}
+	var report struct {
+		Failures int
+	}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Failures != 2 {
+		t.Errorf("expected 2 failures, got %d", report.Failures)
+	}
+}
+
+func TestFields(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "database", `{"host": "db1", "credentials": {"password": "god"}}`,
+		"--key-id", arn1)
+	e.assertGet(nil, "god", "-f", "store.yaml", "database", "--field", "credentials.password")
+
+	e.mustRun("put", "-f", "store.yaml", "database", "--field", "credentials.password=devil")
+	e.assertGet(nil, "devil", "-f", "store.yaml", "database", "--field", "credentials.password")
+	e.assertGet(nil, "db1", "-f", "store.yaml", "database", "--field", "host")
+	e.mustFail("get", "-f", "store.yaml", "database", "--field", "port")
+	e.mustFail("put", "-f", "store.yaml", "database", "secret", "--field", "host=db2")
+
+	e.mustRun("put", "-f", "store.yaml", "app", "--field", "api.key=xyzzy", "--content-type", "yaml")
+	e.assertGet(nil, "api:\n  key: xyzzy\n", "-f", "store.yaml", "app")
+	e.mustRun("put", "-f", "store.yaml", "app", "--field", "api.key=0644")
+	e.assertGet(nil, "api:\n  key: \"0644\"\n", "-f", "store.yaml", "app")
+
+	e.mustRun("put", "-f", "store.yaml", "config", "mode: 0755\nversion: 1.10\nuser: root\n", "--key-id", arn1)
+	e.mustRun("put", "-f", "store.yaml", "config", "--field", "user=nobody")
+	e.assertGet(nil, "mode: 0755\nversion: 1.10\nuser: nobody\n", "-f", "store.yaml", "config")
+	e.assertGet(nil, "0755", "-f", "store.yaml", "config", "--field", "mode")
+	stdout := e.mustRun("export", "-f", "store.yaml", "--format", "json", "--metadata")
+	if !strings.Contains(stdout, `"content_type": "yaml"`) {
+		t.Errorf("expected the content type to be exported, got %q", stdout)
+	}
+}
+
+func TestAgent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires Unix sockets")
+	}
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1)
+	socket := e.path(filepath.Join("agent", "agent.sock"))
+	agent := exec.Command(biscuitBinary, "agent", "-f", "store.yaml", "--socket", socket)
+	agent.Dir = e.dir
+	agent.Env = e.environ(nil)
+	var stderr bytes.Buffer
+	agent.Stderr = &stderr
+	if err := agent.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		agent.Process.Signal(os.Interrupt)
+		agent.Wait()
+	})
+	for i := 0; ; i++ {
+		if _, err := os.Stat(socket); err == nil {
+			break
+		} else if i == 100 {
+			t.Fatalf("the agent did not start: %s", stderr.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Without a reachable KMS, only the agent can decrypt the secrets.
+	withAgent := []string{"BISCUIT_AGENT_SOCK=" + socket, shared.EndpointEnv + "=http://127.0.0.1:1"}
+	if _, _, err := e.runWithEnv(withAgent[1:], "get", "-f", "store.yaml", "password"); err == nil {
+		t.Fatalf("expected get to fail without the agent")
+	}
+	e.assertGet(withAgent, "god", "-f", "store.yaml", "password")
+	stdout, stderr2, err := e.runWithEnv(withAgent, "exec", "-f", "store.yaml", "--", "sh", "-c",
+		`echo "$PASSWORD"`)
+	if err != nil || stdout != "god\n" {
+		t.Errorf("biscuit exec: expected %q, got %q: %v\n%s", "god\n", stdout, err, stderr2)
+	}
+	if stdout, _, _ := e.runWithEnv(withAgent, "export", "-f", "store.yaml"); stdout != "password: god\n" {
+		t.Errorf("biscuit export: expected %q, got %q", "password: god\n", stdout)
+	}
+
+	// The agent reads the file again when it changes.
+	e.mustRun("put", "-f", "store.yaml", "username", "oreilly", "--key-id", arn1)
+	e.assertGet([]string{"BISCUIT_AGENT_SOCK=" + socket}, "oreilly", "-f", "store.yaml", "username")
+
+	// Requests for other stores are not sent to the agent.
+	e.mustRun("put", "-f", "other.yaml", "password", "devil", "-a", "none")
+	e.assertGet(withAgent, "devil", "-f", "other.yaml", "password")
+}
+
+// blackhole returns environment variables that send AWS requests to an emulator on which requests to region
+// do not receive a response until the test ends.
+func blackhole(t *testing.T, region string) []string {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("Authorization"), "/"+region+"/") {
+			<-release
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	return []string{shared.EndpointEnv + "=" + server.URL}
+}
+
+func TestTimeouts(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1+","+arn2)
+
+	unreachable := blackhole(t, region1)
+
+	started := time.Now()
+	e.assertGet(unreachable, "god", "-f", "store.yaml", "--call-timeout", "500ms", "password")
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Errorf("expected get to fall back to %s quickly, took %s", region2, elapsed)
+	}
+
+	_, stderr, err := e.runWithEnv(unreachable, "get", "-f", "store.yaml", "--timeout", "500ms",
+		"--call-timeout", "0", "password")
+	if err == nil || !strings.Contains(stderr, "--timeout") {
+		t.Errorf("expected get to fail with a hint about --timeout: %v\n%s", err, stderr)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	cmd := exec.Command(biscuitBinary, "get", "-f", "store.yaml", "--call-timeout", "0", "password")
+	cmd.Dir = e.dir
+	cmd.Env = e.environ(unreachable)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	started = time.Now()
+	cmd.Process.Signal(os.Interrupt)
+	if err := cmd.Wait(); err == nil {
+		t.Errorf("expected an interrupted get to fail")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("expected get to stop promptly when interrupted, took %s", elapsed)
+	}
+}
+
+func TestHedging(t *testing.T) {
+	e := newEnv(t)
+	e.mustRun("put", "-f", "store.yaml", "password", "god", "--key-id", arn1+","+arn2)
+	unreachable := blackhole(t, region1)
+
+	started := time.Now()
+	stdout, stderr, err := e.runWithEnv(unreachable, "get", "-f", "store.yaml", "--hedge-after", "100ms",
+		"--verbose", "password")
+	if err != nil || stdout != "god" {
+		t.Fatalf("biscuit get: expected %q, got %q: %v\n%s", "god", stdout, err, stderr)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("expected get to hedge to %s, took %s", region2, elapsed)
+	}
+	if !strings.Contains(stderr, "under kms "+region2) {
+		t.Errorf("expected --verbose to report %s: %s", region2, stderr)
+	}
+
+	_, stderr, err = e.run("get", "-f", "store.yaml", "--verbose", "-p", region2, "password")
+	if err != nil || !strings.Contains(stderr, "under kms "+region2) {
+		t.Errorf("expected --verbose to report %s: %v\n%s", region2, err, stderr)
+	}
+}